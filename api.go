@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/douguohai/frp-client/message"
+	"github.com/douguohai/frp-client/service"
+)
+
+// apiError 接口错误，携带 HTTP 状态码和机器可读错误码
+type apiError struct {
+	status int
+	code   string
+	msg    string
+	fields []message.FieldError
+}
+
+func (e *apiError) Error() string {
+	return e.msg
+}
+
+func newAPIError(status int, code, msg string) *apiError {
+	return &apiError{status: status, code: code, msg: msg}
+}
+
+// toAPIError 业务错误转换为接口错误，未识别的错误视为内部错误
+func toAPIError(err error) *apiError {
+	var ae *apiError
+	if errors.As(err, &ae) {
+		return ae
+	}
+	if fields := fieldErrors(err); fields != nil {
+		ae := newAPIError(http.StatusUnprocessableEntity, message.CodeValidationFailed, err.Error())
+		ae.fields = fields
+		return ae
+	}
+	switch {
+	case errors.Is(err, service.ErrProxyNotFound):
+		return newAPIError(http.StatusNotFound, message.CodeNotFound, err.Error())
+	case errors.Is(err, service.ErrProxyExists):
+		return newAPIError(http.StatusConflict, message.CodeConflict, err.Error())
+	case errors.Is(err, service.ErrProxyConf):
+		return newAPIError(http.StatusUnprocessableEntity, message.CodeValidationFailed, err.Error())
+	case errors.Is(err, service.ErrServerNotConfigured):
+		return newAPIError(http.StatusConflict, message.CodeServerNotConfigured, err.Error())
+	case errors.Is(err, service.ErrNoFreePort), errors.Is(err, service.ErrLocalPortInUse):
+		return newAPIError(http.StatusConflict, message.CodeConflict, err.Error())
+	case errors.Is(err, service.ErrDashboardNotConfigured):
+		return newAPIError(http.StatusConflict, message.CodeDashboardFailed, err.Error())
+	case errors.Is(err, service.ErrDashboardFailed):
+		return newAPIError(http.StatusBadGateway, message.CodeDashboardFailed, err.Error())
+	case errors.Is(err, service.ErrConnectFailed):
+		return newAPIError(http.StatusBadGateway, message.CodeConnectFailed, err.Error())
+	}
+	return newAPIError(http.StatusInternalServerError, message.CodeInternal, err.Error())
+}
+
+// writeAPIData 输出成功响应
+func writeAPIData(writer http.ResponseWriter, status int, data interface{}) {
+	writeAPIResponse(writer, status, message.Response{
+		Status: 0,
+		Code:   message.CodeOK,
+		Msg:    "操作成功",
+		Data:   data,
+	})
+}
+
+// writeAPIError 输出失败响应
+func writeAPIError(writer http.ResponseWriter, err error) {
+	ae := toAPIError(err)
+	writeAPIResponse(writer, ae.status, message.Response{
+		Status: -1,
+		Code:   ae.code,
+		Msg:    ae.msg,
+		Errors: ae.fields,
+	})
+}
+
+// fieldErrors 校验错误中的字段错误，其他错误返回 nil
+func fieldErrors(err error) []message.FieldError {
+	var ve *service.ValidationError
+	if errors.As(err, &ve) {
+		return ve.Fields
+	}
+	return nil
+}
+
+func writeAPIResponse(writer http.ResponseWriter, status int, resp message.Response) {
+	data, _ := json.Marshal(resp)
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	writer.Write(data)
+}
+
+// decodeBody 解析 JSON 请求体
+func decodeBody(request *http.Request, v interface{}) error {
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		return newAPIError(http.StatusBadRequest, message.CodeInvalidRequest, "读取请求失败")
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return newAPIError(http.StatusBadRequest, message.CodeInvalidRequest, "请求格式错误: "+err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/douguohai/frp-client/message"
+	"github.com/gorilla/mux"
+)
+
+// registerV1Routes 注册 /api/v1 资源接口，响应统一为 message.Response
+func registerV1Routes(router *mux.Router) {
+	router.HandleFunc("/proxies", v1ListProxies).Methods("GET")
+	router.HandleFunc("/proxies", v1CreateProxy).Methods("POST")
+	router.HandleFunc("/proxies/{name}", v1GetProxy).Methods("GET")
+	router.HandleFunc("/proxies/{name}", v1UpdateProxy).Methods("PATCH")
+	router.HandleFunc("/proxies/{name}", v1DeleteProxy).Methods("DELETE")
+	router.HandleFunc("/proxies/{name}/enable", v1SetProxyEnabled(true)).Methods("POST")
+	router.HandleFunc("/proxies/{name}/disable", v1SetProxyEnabled(false)).Methods("POST")
+
+	router.HandleFunc("/proxies/{name}/protocol-test", v1GetProtocolTest).Methods("GET")
+	router.HandleFunc("/proxies/{name}/protocol-test", v1StartProtocolTest).Methods("POST")
+	router.HandleFunc("/proxies/{name}/protocol-test", v1StopProtocolTest).Methods("DELETE")
+	router.HandleFunc("/proxies/{name}/stats", v1ProxyStats).Methods("GET")
+	router.HandleFunc("/groups", v1ListGroups).Methods("GET")
+
+	router.HandleFunc("/server", v1GetServer).Methods("GET")
+	router.HandleFunc("/server/connect", v1Connect).Methods("POST")
+	router.HandleFunc("/server/disconnect", v1Disconnect).Methods("POST")
+	router.HandleFunc("/server/free-port", v1FreePort).Methods("GET")
+	router.HandleFunc("/server/dashboard", v1Dashboard).Methods("GET")
+
+	router.HandleFunc("/events", eventsHandler).Methods("GET")
+	router.HandleFunc("/logs", logsHandler).Methods("GET")
+	router.HandleFunc("/logs/tail", logsTailHandler).Methods("GET")
+	router.HandleFunc("/audit", auditHandler).Methods("GET")
+	router.HandleFunc("/backup", backupHandler).Methods("GET")
+	router.HandleFunc("/restore", restoreHandler).Methods("POST")
+}
+
+// GET /api/v1/proxies
+func v1ListProxies(writer http.ResponseWriter, request *http.Request) {
+	writeAPIData(writer, http.StatusOK, message.ProxyMsgVos{
+		Items: proxies.List(),
+		Time:  time.Now().UnixNano(),
+	})
+}
+
+// POST /api/v1/proxies
+func v1CreateProxy(writer http.ResponseWriter, request *http.Request) {
+	proxy := message.ProxyMsg{}
+	if err := decodeBody(request, &proxy); err != nil {
+		writeAPIError(writer, err)
+		return
+	}
+	created, err := proxies.Create(requestActor(request), proxy)
+	if err != nil {
+		writeAPIError(writer, err)
+		return
+	}
+	writeAPIData(writer, http.StatusCreated, created)
+}
+
+// GET /api/v1/proxies/{name}
+func v1GetProxy(writer http.ResponseWriter, request *http.Request) {
+	proxy, err := proxies.Get(mux.Vars(request)["name"])
+	if err != nil {
+		writeAPIError(writer, err)
+		return
+	}
+	writeAPIData(writer, http.StatusOK, proxy)
+}
+
+// PATCH /api/v1/proxies/{name}
+func v1UpdateProxy(writer http.ResponseWriter, request *http.Request) {
+	name := mux.Vars(request)["name"]
+	patch := message.ProxyPatch{}
+	if err := decodeBody(request, &patch); err != nil {
+		writeAPIError(writer, err)
+		return
+	}
+
+	updated, err := proxies.Patch(requestActor(request), name, patch)
+	if err != nil {
+		writeAPIError(writer, err)
+		return
+	}
+	writeAPIData(writer, http.StatusOK, updated)
+}
+
+// DELETE /api/v1/proxies/{name}
+func v1DeleteProxy(writer http.ResponseWriter, request *http.Request) {
+	if err := proxies.Delete(requestActor(request), mux.Vars(request)["name"]); err != nil {
+		writeAPIError(writer, err)
+		return
+	}
+	writeAPIData(writer, http.StatusOK, nil)
+}
+
+// POST /api/v1/proxies/{name}/enable 和 /disable
+// 开启时可带 ProxyExpiry 请求体设置到期
+func v1SetProxyEnabled(enabled bool) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		name := mux.Vars(request)["name"]
+		var proxy message.ProxyMsgVo
+		var err error
+		if enabled && request.ContentLength != 0 {
+			expiry := message.ProxyExpiry{}
+			if err := decodeBody(request, &expiry); err != nil {
+				writeAPIError(writer, err)
+				return
+			}
+			proxy, err = proxies.EnableWithExpiry(requestActor(request), name, expiry)
+		} else {
+			proxy, err = proxies.SetEnabled(requestActor(request), name, enabled)
+		}
+		if err != nil {
+			writeAPIError(writer, err)
+			return
+		}
+		writeAPIData(writer, http.StatusOK, proxy)
+	}
+}
+
+// GET /api/v1/proxies/{name}/protocol-test
+func v1GetProtocolTest(writer http.ResponseWriter, request *http.Request) {
+	test, err := proxies.ProtocolTest(mux.Vars(request)["name"])
+	if err != nil {
+		writeAPIError(writer, err)
+		return
+	}
+	writeAPIData(writer, http.StatusOK, test)
+}
+
+// POST /api/v1/proxies/{name}/protocol-test
+func v1StartProtocolTest(writer http.ResponseWriter, request *http.Request) {
+	test, err := proxies.StartProtocolTest(mux.Vars(request)["name"])
+	if err != nil {
+		writeAPIError(writer, err)
+		return
+	}
+	writeAPIData(writer, http.StatusOK, test)
+}
+
+// DELETE /api/v1/proxies/{name}/protocol-test
+func v1StopProtocolTest(writer http.ResponseWriter, request *http.Request) {
+	name := mux.Vars(request)["name"]
+	proxies.StopProtocolTest(name)
+	test, err := proxies.ProtocolTest(name)
+	if err != nil {
+		writeAPIError(writer, err)
+		return
+	}
+	writeAPIData(writer, http.StatusOK, test)
+}
+
+// GET /api/v1/proxies/{name}/stats?window=1h
+func v1ProxyStats(writer http.ResponseWriter, request *http.Request) {
+	window, err := parseStatsWindow(request.URL.Query().Get("window"))
+	if err != nil {
+		writeAPIError(writer, err)
+		return
+	}
+	stats, err := proxies.Stats(mux.Vars(request)["name"], window)
+	if err != nil {
+		writeAPIError(writer, err)
+		return
+	}
+	writeAPIData(writer, http.StatusOK, stats)
+}
+
+// parseStatsWindow 解析统计时长，为空时返回 0，即全部保留的数据
+func parseStatsWindow(window string) (time.Duration, error) {
+	if window == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(window)
+	if err != nil || d <= 0 {
+		return 0, newAPIError(http.StatusBadRequest, message.CodeInvalidRequest, fmt.Sprintf("统计时长 %q 格式错误，如 30m、1h", window))
+	}
+	return d, nil
+}
+
+// GET /api/v1/groups
+func v1ListGroups(writer http.ResponseWriter, request *http.Request) {
+	writeAPIData(writer, http.StatusOK, message.ProxyGroups{
+		Items: proxies.Groups(),
+	})
+}
+
+// GET /api/v1/server
+func v1GetServer(writer http.ResponseWriter, request *http.Request) {
+	writeAPIData(writer, http.StatusOK, connection.Info())
+}
+
+// POST /api/v1/server/connect
+func v1Connect(writer http.ResponseWriter, request *http.Request) {
+	serverInfo := message.ConnectServerMsg{}
+	if err := decodeBody(request, &serverInfo); err != nil {
+		writeAPIError(writer, err)
+		return
+	}
+	if err := connection.Connect(requestActor(request), serverInfo); err != nil {
+		writeAPIError(writer, err)
+		return
+	}
+	writeAPIData(writer, http.StatusOK, connection.Info())
+}
+
+// POST /api/v1/server/disconnect
+func v1Disconnect(writer http.ResponseWriter, request *http.Request) {
+	if err := connection.Disconnect(requestActor(request)); err != nil {
+		writeAPIError(writer, err)
+		return
+	}
+	writeAPIData(writer, http.StatusOK, connection.Info())
+}
+
+// GET /api/v1/server/dashboard
+func v1Dashboard(writer http.ResponseWriter, request *http.Request) {
+	info, err := connection.DashboardInfo()
+	if err != nil {
+		writeAPIError(writer, err)
+		return
+	}
+	writeAPIData(writer, http.StatusOK, info)
+}
+
+// GET /api/v1/server/free-port?type=tcp
+func v1FreePort(writer http.ResponseWriter, request *http.Request) {
+	port, err := proxies.FreeRemotePort(request.URL.Query().Get("type"))
+	if err != nil {
+		writeAPIError(writer, err)
+		return
+	}
+	writeAPIData(writer, http.StatusOK, port)
+}
@@ -0,0 +1,513 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/douguohai/frp-client/message"
+	"github.com/douguohai/frp-client/service"
+)
+
+func TestServerReconnect(t *testing.T) {
+	e := integration(t)
+	reconnects := connection.Reconnects()
+	e.call(t, "POST", "/api/v1/server/disconnect", nil, nil)
+	if connection.RunStatus() != 0 {
+		t.Fatalf("断开后连接状态为 %d", connection.RunStatus())
+	}
+	var info message.ServiceInfo
+	e.call(t, "POST", "/api/v1/server/connect", e.connectMsg(), &info)
+	if !connection.Running() {
+		t.Fatalf("连接状态为 %d", connection.RunStatus())
+	}
+
+	// 重新连接按 frpc 的登录计数
+	for deadline := time.Now().Add(5 * time.Second); connection.Reconnects() != reconnects+1; time.Sleep(50 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("重新连接后重连次数为 %d，之前为 %d", connection.Reconnects(), reconnects)
+		}
+	}
+}
+
+func TestProxyValidation(t *testing.T) {
+	e := integration(t)
+	e.expectFieldErrors(t, "POST", "/api/v1/proxies", message.ProxyMsg{
+		ProxyName:  "../test",
+		LocalPort:  e.echoPort,
+		RemotePort: e.serverPort,
+	}, "proxyName", "remotePort")
+}
+
+func TestProxyLifecycle(t *testing.T) {
+	e := integration(t)
+	remotePort, editPort := freePort(t), freePort(t)
+	e.addProxy(t, message.ProxyMsg{
+		ProxyName:  "test-life",
+		LocalPort:  e.echoPort,
+		RemotePort: remotePort,
+	})
+
+	e.enable(t, "test-life")
+	waitEcho(t, remotePort)
+	expectRunStatus(t, "test-life", "running")
+
+	// 修改后保持开启，只重启该代理
+	e.call(t, "PATCH", "/api/v1/proxies/test-life", message.ProxyPatch{RemotePort: &editPort}, nil)
+	waitClosed(t, remotePort)
+	waitEcho(t, editPort)
+
+	// 旧接口改名，originName 为修改前的名称
+	renamed := "test-life-renamed"
+	e.call(t, "POST", "/api/editProxy", message.ProxyEditMsg{
+		ProxyPatch: message.ProxyPatch{ProxyName: &renamed},
+		OriginName: "test-life",
+	}, nil)
+	e.callFails(t, "GET", "/api/v1/proxies/test-life", nil)
+	if !e.proxy(t, "test-life-renamed").Status {
+		t.Fatal("改名后代理被关闭")
+	}
+	waitEcho(t, editPort)
+	expectRunStatus(t, "test-life-renamed", "running")
+
+	// 旧接口关闭，覆盖 /api/openProxy 的重新加载流程
+	e.call(t, "PUT", "/api/openProxy", message.ProxyStatus{ProxyName: "test-life-renamed", Status: false}, nil)
+	waitClosed(t, editPort)
+
+	e.call(t, "DELETE", "/api/v1/proxies/test-life-renamed", nil, nil)
+	e.callFails(t, "GET", "/api/v1/proxies/test-life-renamed", nil)
+}
+
+// 旧接口只修改提交的字段，编辑表单中没有的定时和健康检查保持不变
+func TestLegacyEditKeepsFields(t *testing.T) {
+	e := integration(t)
+	schedule := message.ScheduleConf{Windows: "mon 09:00-10:00", Timezone: "UTC"}
+	healthCheck := message.HealthCheckConf{Type: "tcp", IntervalS: 10, TimeoutS: 3, MaxFailed: 3}
+	e.addProxy(t, message.ProxyMsg{
+		ProxyName:   "test-edit",
+		LocalPort:   e.echoPort,
+		Schedule:    schedule,
+		HealthCheck: healthCheck,
+	})
+
+	e.call(t, "POST", "/api/editProxy", map[string]interface{}{
+		"originName": "test-edit",
+		"proxyName":  "test-edit",
+		"localPort":  e.echoPort + 1,
+	}, nil)
+	p := e.proxy(t, "test-edit")
+	if p.LocalPort != e.echoPort+1 {
+		t.Fatalf("本地端口为 %d", p.LocalPort)
+	}
+	if p.Schedule != schedule || p.HealthCheck != healthCheck {
+		t.Fatalf("编辑后定时为 %+v，健康检查为 %+v", p.Schedule, p.HealthCheck)
+	}
+	e.call(t, "DELETE", "/api/v1/proxies/test-edit", nil, nil)
+}
+
+func TestServerAllocatedPort(t *testing.T) {
+	e := integration(t)
+	e.addProxy(t, message.ProxyMsg{ProxyName: "test-auto", LocalPort: e.echoPort})
+	e.enable(t, "test-auto")
+	record := waitRunning(t, "test-auto")
+	_, port, err := net.SplitHostPort(record.RemoteAddr)
+	if err != nil {
+		t.Fatalf("远程地址 %q: %v", record.RemoteAddr, err)
+	}
+	var remote int
+	fmt.Sscan(port, &remote)
+	waitEcho(t, remote)
+}
+
+func TestPortNotAllowed(t *testing.T) {
+	e := integration(t)
+	e.addProxy(t, message.ProxyMsg{
+		ProxyName:  "test-denied",
+		LocalPort:  e.echoPort,
+		RemotePort: testDeniedPort,
+	})
+	e.enable(t, "test-denied")
+	waitRecord(t, "test-denied", func(p message.ProxyMsg) bool {
+		return p.ErrReason == message.ProxyErrPortNotAllowed
+	})
+}
+
+func TestFreePort(t *testing.T) {
+	e := integration(t)
+	first, second := freePort(t), freePort(t)
+	portRange := proxies.PortRange
+	proxies.PortRange = []int{first, second}
+	t.Cleanup(func() { proxies.PortRange = portRange })
+
+	var free message.FreePort
+	e.call(t, "GET", "/api/v1/server/free-port?type=tcp", nil, &free)
+	if free.Port != first && free.Port != second {
+		t.Fatalf("端口 %d 不在查找范围内", free.Port)
+	}
+}
+
+func TestProbeLocal(t *testing.T) {
+	e := integration(t)
+	e.addProxy(t, message.ProxyMsg{
+		ProxyName:  "test-probe",
+		LocalPort:  freePort(t),
+		RemotePort: freePort(t),
+	})
+	e.enable(t, "test-probe")
+	proxies.ProbeLocal()
+	if vo := e.proxy(t, "test-probe"); vo.LocalStatus != message.LocalStatusDown {
+		t.Fatalf("本地端口未监听时状态为 %q", vo.LocalStatus)
+	}
+
+	health := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer health.Close()
+	healthPort := health.Listener.Addr().(*net.TCPAddr).Port
+	probePath, probeStatus := "/health", http.StatusNoContent
+	e.call(t, "PATCH", "/api/v1/proxies/test-probe", message.ProxyPatch{
+		LocalPort:   &healthPort,
+		ProbePath:   &probePath,
+		ProbeStatus: &probeStatus,
+	}, nil)
+	proxies.ProbeLocal()
+	if vo := e.proxy(t, "test-probe"); vo.LocalStatus != message.LocalStatusUp {
+		t.Fatalf("探测成功时状态为 %q (%s)", vo.LocalStatus, vo.LocalErr)
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	e := integration(t)
+	backend, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+	e.addProxy(t, message.ProxyMsg{
+		ProxyName:  "test-health",
+		LocalPort:  backend.Addr().(*net.TCPAddr).Port,
+		RemotePort: freePort(t),
+		HealthCheck: message.HealthCheckConf{
+			Type:      "tcp",
+			IntervalS: 1,
+			TimeoutS:  1,
+			MaxFailed: 1,
+		},
+	})
+	e.enable(t, "test-health")
+	waitRecord(t, "test-health", func(p message.ProxyMsg) bool {
+		return p.HealthStatus == message.HealthStatusPassed
+	})
+	backend.Close()
+	waitRecord(t, "test-health", func(p message.ProxyMsg) bool {
+		return p.HealthStatus == message.HealthStatusFailed && p.ErrReason == message.ProxyErrHealthCheck
+	})
+}
+
+func TestProxyGroups(t *testing.T) {
+	e := integration(t)
+	groupPort := freePort(t)
+	secondEcho, err := startEcho()
+	if err != nil {
+		t.Fatal(err)
+	}
+	members := map[string]int{"test-lb-1": e.echoPort, "test-lb-2": secondEcho}
+	for _, name := range []string{"test-lb-1", "test-lb-2"} {
+		e.addProxy(t, message.ProxyMsg{
+			ProxyName:  name,
+			LocalPort:  members[name],
+			RemotePort: groupPort,
+			Group:      "test-group",
+			GroupKey:   "test-key",
+		})
+		e.enable(t, name)
+	}
+	e.expectFieldErrors(t, "POST", "/api/v1/proxies", message.ProxyMsg{
+		ProxyName:  "test-lb-3",
+		LocalPort:  e.echoPort,
+		RemotePort: freePort(t),
+		Group:      "test-group",
+		GroupKey:   "other-key",
+	}, "remotePort", "groupKey")
+	waitEcho(t, groupPort)
+	for name := range members {
+		waitRunning(t, name)
+	}
+
+	var groups message.ProxyGroups
+	e.call(t, "GET", "/api/v1/groups", nil, &groups)
+	if len(groups.Items) != 1 || len(groups.Items[0].Members) != 2 || groups.Items[0].Enabled != 2 {
+		t.Fatalf("负载均衡组不符合预期: %+v", groups.Items)
+	}
+
+	// 组密钥只写不返回，留空修改时保持原密钥
+	for _, path := range []string{"/api/v1/proxies/test-lb-1", "/api/getProxy"} {
+		resp, err := e.request("GET", path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if strings.Contains(string(body), "test-key") {
+			t.Fatalf("%s 返回了组密钥", path)
+		}
+	}
+	if !e.proxy(t, "test-lb-1").HasGroupKey {
+		t.Fatal("hasGroupKey 为 false")
+	}
+	empty := ""
+	e.call(t, "PATCH", "/api/v1/proxies/test-lb-1", message.ProxyPatch{GroupKey: &empty}, nil)
+	if key := proxies.Records("test-lb-1")[0].GroupKey; key != "test-key" {
+		t.Fatalf("留空修改后组密钥为 %q", key)
+	}
+}
+
+func TestProxyProtocol(t *testing.T) {
+	e := integration(t)
+	e.expectFieldErrors(t, "POST", "/api/v1/proxies", message.ProxyMsg{
+		ProxyName:            "test-pp-udp",
+		Type:                 "udp",
+		LocalPort:            e.echoPort,
+		ProxyProtocolVersion: "v1",
+	}, "proxyProtocolVersion")
+
+	localPort, ppPort := freePort(t), freePort(t)
+	e.addProxy(t, message.ProxyMsg{
+		ProxyName:            "test-pp",
+		LocalPort:            localPort,
+		RemotePort:           ppPort,
+		ProxyProtocolVersion: "v2",
+	})
+	e.enable(t, "test-pp")
+	e.call(t, "POST", "/api/v1/proxies/test-pp/protocol-test", nil, nil)
+
+	var source, reply string
+	var err error
+	for deadline := time.Now().Add(testWait); time.Now().Before(deadline); time.Sleep(200 * time.Millisecond) {
+		source, reply, err = protocolReply(ppPort)
+		if err == nil && reply != "" {
+			break
+		}
+	}
+	if want := "source: " + source; !strings.Contains(reply, "PROXY protocol v2") || !strings.Contains(reply, want) {
+		t.Fatalf("测试监听回复不符合预期: %q, %v", reply, err)
+	}
+	var test message.ProtocolTest
+	e.call(t, "GET", "/api/v1/proxies/test-pp/protocol-test", nil, &test)
+	if !test.Running || len(test.Items) == 0 || test.Items[0].Version != 2 || test.Items[0].SourceAddr != source {
+		t.Fatalf("测试记录不符合预期: %+v", test)
+	}
+
+	e.call(t, "DELETE", "/api/v1/proxies/test-pp", nil, nil)
+	l, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", localPort))
+	if err != nil {
+		t.Fatalf("删除代理后测试监听未停止: %v", err)
+	}
+	l.Close()
+}
+
+// protocolReply 连接远程端口并读取测试监听的回复，返回本端地址，即协议头中应有的访问者地址
+func protocolReply(port int) (string, string, error) {
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", port), time.Second)
+	if err != nil {
+		return "", "", err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	reply, err := io.ReadAll(conn)
+	return conn.LocalAddr().String(), string(reply), err
+}
+
+func TestProxyStats(t *testing.T) {
+	e := integration(t)
+	statsPort := freePort(t)
+	e.addProxy(t, message.ProxyMsg{
+		ProxyName:  "test-stats",
+		LocalPort:  e.echoPort,
+		RemotePort: statsPort,
+		Stats:      true,
+	})
+	e.enable(t, "test-stats")
+	waitEcho(t, statsPort)
+	if err := echo(statsPort); err != nil {
+		t.Fatal(err)
+	}
+
+	var stats message.ProxyStats
+	e.call(t, "GET", "/api/proxies/test-stats/stats", nil, &stats)
+	if stats.BytesIn == 0 || stats.BytesOut != stats.BytesIn || stats.TotalConns < 2 {
+		t.Fatalf("流量统计不符合预期: %+v", stats)
+	}
+	proxies.SampleStats()
+	newName := "test-stats-2"
+	e.call(t, "PATCH", "/api/v1/proxies/test-stats", message.ProxyPatch{ProxyName: &newName}, nil)
+	var renamed message.ProxyStats
+	e.call(t, "GET", "/api/v1/proxies/test-stats-2/stats?window=1h", nil, &renamed)
+	if len(renamed.Items) != 1 || renamed.BytesIn != stats.BytesIn || renamed.Items[0].NewConns != stats.TotalConns {
+		t.Fatalf("改名后流量统计不符合预期: %+v", renamed)
+	}
+
+	e.call(t, "DELETE", "/api/v1/proxies/test-stats-2", nil, nil)
+	var left message.ProxyStats
+	if err := store.Read(service.StatsCollection, "test-stats-2", &left); err == nil {
+		t.Fatal("删除代理后流量统计仍存在")
+	}
+}
+
+func TestDashboard(t *testing.T) {
+	e := integration(t)
+	var info message.DashboardInfo
+	e.call(t, "GET", "/api/v1/server/dashboard", nil, &info)
+	if info.Version == "" || info.ClientCounts < 1 {
+		t.Fatalf("服务器信息不符合预期: %+v", info)
+	}
+	wrong := service.NewDashboardClient(fmt.Sprintf("http://127.0.0.1:%d/", e.dashboardPort), testDashboardUser, "wrong")
+	if _, err := wrong.ServerInfo(); !errors.Is(err, service.ErrDashboardFailed) {
+		t.Fatalf("密码错误时返回 %v", err)
+	}
+
+	dashPort := freePort(t)
+	e.addProxy(t, message.ProxyMsg{
+		ProxyName:  "test-dash",
+		LocalPort:  e.echoPort,
+		RemotePort: dashPort,
+	})
+	e.enable(t, "test-dash")
+	waitEcho(t, dashPort)
+
+	// dashboard 数据在后台刷新，缓存 5 秒
+	var proxy message.ProxyMsgVo
+	for deadline := time.Now().Add(2 * testWait); time.Now().Before(deadline); time.Sleep(500 * time.Millisecond) {
+		proxy = e.proxy(t, "test-dash")
+		if proxy.ServerStatus == "online" && proxy.TodayTrafficIn > 0 && proxy.LastStartTime != "" {
+			return
+		}
+	}
+	t.Fatalf("frps 代理信息不符合预期: %+v", proxy)
+}
+
+// TestSchedule 以固定时间驱动调度器，检查定时开关、手动开关的保持及下一次切换时间
+func TestSchedule(t *testing.T) {
+	e := integration(t)
+	const name = "test-schedule"
+	e.expectFieldErrors(t, "POST", "/api/v1/proxies", message.ProxyMsg{
+		ProxyName: name,
+		LocalPort: e.echoPort,
+		Schedule:  message.ScheduleConf{Cron: "* 25 * * *", Timezone: "Mars/Olympus"},
+	}, "schedule.cron", "schedule.timezone")
+	e.addProxy(t, message.ProxyMsg{
+		ProxyName: name,
+		LocalPort: e.echoPort,
+		Schedule:  message.ScheduleConf{Windows: "mon 09:00-10:00; fri 22:00-02:00", Timezone: "UTC"},
+	})
+
+	expect := func(at time.Time, status bool, next time.Time, nextStatus bool) {
+		t.Helper()
+		scheduler.Evaluate(at)
+		vo := e.proxy(t, name)
+		if vo.Status != status || vo.NextTransition != next.UnixNano() || vo.NextStatus != nextStatus {
+			t.Fatalf("%s 时状态为 %v，下一次切换为 %v %v", at, vo.Status, time.Unix(0, vo.NextTransition).UTC(), vo.NextStatus)
+		}
+	}
+	// 2024-01-01 为周一
+	monday := time.Date(2024, 1, 1, 9, 30, 0, 0, time.UTC)
+	expect(monday, true, monday.Add(30*time.Minute), false)
+	// 手动关闭保持到下一次切换
+	e.call(t, "POST", "/api/v1/proxies/"+name+"/disable", nil, nil)
+	expect(monday.Add(15*time.Minute), false, monday.Add(30*time.Minute), false)
+	friday := time.Date(2024, 1, 5, 22, 0, 0, 0, time.UTC)
+	expect(friday, true, friday.Add(4*time.Hour), false)
+	expect(friday.Add(4*time.Hour), false, monday.AddDate(0, 0, 7).Add(-30*time.Minute), true)
+
+	// 改为 cron 后立即按新配置检查，周六不在范围内
+	cron := message.ScheduleConf{Cron: "* 9-17 * * mon-fri", Timezone: "Asia/Shanghai"}
+	e.enable(t, name)
+	e.call(t, "PATCH", "/api/v1/proxies/"+name, message.ProxyPatch{Schedule: &cron}, nil)
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	saturday := time.Date(2024, 1, 6, 10, 0, 0, 0, shanghai)
+	expect(saturday, false, time.Date(2024, 1, 8, 9, 0, 0, 0, shanghai), true)
+
+	var records message.AuditRecords
+	e.call(t, "GET", "/api/v1/audit?source=system&target="+name, nil, &records)
+	if len(records.Items) != 4 || records.Items[0].Client != service.ScheduleActor.Client {
+		t.Fatalf("定时开关的审计记录为 %+v", records.Items)
+	}
+}
+
+// TestExpiry 检查到期设置的校验、即将到期事件，以及到期后的删除和关闭
+func TestExpiry(t *testing.T) {
+	e := integration(t)
+	const name, other = "test-expire", "test-expire-disable"
+	e.expectFieldErrors(t, "POST", "/api/v1/proxies", message.ProxyMsg{
+		ProxyName:    name,
+		LocalPort:    e.echoPort,
+		ExpireIn:     "soon",
+		ExpireAction: "archive",
+	}, "expireIn", "expireAction")
+
+	vo := e.addProxy(t, message.ProxyMsg{
+		ProxyName:    name,
+		LocalPort:    e.echoPort,
+		ExpireIn:     "1h",
+		ExpireAction: message.ExpireActionDelete,
+	})
+	if vo.ExpiresInS <= 3590 || vo.ExpiresInS > 3600 || vo.ExpireAction != message.ExpireActionDelete {
+		t.Fatalf("新增后到期设置为 %d 秒 %s", vo.ExpiresInS, vo.ExpireAction)
+	}
+	e.call(t, "POST", "/api/v1/proxies/"+name+"/enable", message.ProxyExpiry{ExpireIn: "3m"}, &vo)
+	if !vo.Status || vo.ExpiresInS > 180 || vo.ExpireAction != message.ExpireActionDelete {
+		t.Fatalf("临时开启后状态为 %v，到期设置为 %d 秒 %s", vo.Status, vo.ExpiresInS, vo.ExpireAction)
+	}
+
+	// 到期前推送一次即将到期事件
+	ch := events.subscribe()
+	defer events.unsubscribe(ch)
+	now := time.Now()
+	scheduler.Evaluate(now)
+	scheduler.Evaluate(now.Add(time.Second))
+	warned := 0
+	for len(ch) > 0 {
+		ev := <-ch
+		if body, ok := ev.Body.(message.ProxyExpiringEvent); ok && ev.Type == message.EventExpiring && body.ProxyName == name {
+			warned++
+		}
+	}
+	if warned != 1 {
+		t.Fatalf("即将到期事件推送了 %d 次", warned)
+	}
+
+	scheduler.Evaluate(now.Add(4 * time.Minute))
+	if len(proxies.Records(name)) != 0 {
+		t.Fatal("到期后代理未删除")
+	}
+	var records message.AuditRecords
+	e.call(t, "GET", "/api/v1/audit?action=delete&target="+name, nil, &records)
+	if len(records.Items) != 1 || records.Items[0].Client != service.ExpireActor.Client {
+		t.Fatalf("到期删除的审计记录为 %+v", records.Items)
+	}
+
+	// 到期关闭后取消到期时间，手动关闭同样取消
+	e.addProxy(t, message.ProxyMsg{ProxyName: other, LocalPort: e.echoPort})
+	past := time.Now().Add(-time.Minute).UnixNano()
+	e.expectFieldErrors(t, "PATCH", "/api/v1/proxies/"+other, message.ProxyPatch{ExpireAt: &past}, "expireAt")
+	e.call(t, "POST", "/api/v1/proxies/"+other+"/enable", message.ProxyExpiry{ExpireIn: "10m"}, nil)
+	scheduler.Evaluate(time.Now().Add(11 * time.Minute))
+	if vo = e.proxy(t, other); vo.Status || vo.ExpireAt != 0 {
+		t.Fatalf("到期后状态为 %v，到期时间为 %d", vo.Status, vo.ExpireAt)
+	}
+	e.call(t, "POST", "/api/v1/proxies/"+other+"/enable", message.ProxyExpiry{ExpireIn: "10m"}, nil)
+	e.call(t, "POST", "/api/v1/proxies/"+other+"/disable", nil, &vo)
+	if vo.ExpireAt != 0 {
+		t.Fatal("手动关闭后到期时间未取消")
+	}
+}
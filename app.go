@@ -3,8 +3,17 @@ package main
 import (
 	"context"
 	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/douguohai/frp-client/logger"
+	"github.com/douguohai/frp-client/message"
+	"github.com/douguohai/frp-client/service"
 )
 
+// guiActor wails 绑定方法的操作来源
+var guiActor = message.AuditActor{Source: message.AuditSourceGUI, Client: "wails"}
+
 // App struct
 type App struct {
 	ctx context.Context
@@ -19,13 +28,24 @@ func NewApp() *App {
 // so we can call the runtime methods
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
-	go doCron()
+	events.setWailsContext(ctx)
+	if scheduler != nil {
+		go scheduler.Run()
+	}
 }
 
 func (a *App) shutdown(ctx context.Context) bool {
 	a.ctx = ctx
-	ticker.Stop()
-	unlockConfig()
+	if scheduler != nil {
+		scheduler.Stop()
+	}
+	if proxies != nil {
+		// 保存最后一次采样之后的流量
+		proxies.SampleStats()
+	}
+	if connection != nil {
+		connection.Close()
+	}
 	return false
 }
 
@@ -35,8 +55,173 @@ func (a *App) Greet(name string) string {
 }
 
 // Greet returns a greeting for the given name
-func (a *App) SetFrpServiceConfig(ip string, port int) string {
-	serverIp = ip
-	serverPort = port
-	return "ok"
+func (a *App) SetFrpServiceConfig(ip string, port int) (string, error) {
+	if err := checkStore(); err != nil {
+		return "", err
+	}
+	server := connection.Server()
+	server.ServerIp = ip
+	server.ServerPort = port
+	connection.ChangeServer(guiActor, server)
+	return "ok", nil
+}
+
+// GetDashboardInfo 通过 frps dashboard 获取服务器信息
+func (a *App) GetDashboardInfo() (message.DashboardInfo, error) {
+	if err := checkStore(); err != nil {
+		return message.DashboardInfo{}, err
+	}
+	return connection.DashboardInfo()
+}
+
+// ListProxies 获取代理列表
+func (a *App) ListProxies() ([]message.ProxyMsgVo, error) {
+	if err := checkStore(); err != nil {
+		return nil, err
+	}
+	return proxies.List(), nil
+}
+
+// AddProxy 新增代理
+func (a *App) AddProxy(proxy message.ProxyMsg) (message.ProxyMsgVo, error) {
+	if err := checkStore(); err != nil {
+		return message.ProxyMsgVo{}, err
+	}
+	return proxies.Create(guiActor, proxy)
+}
+
+// UpdateProxy 修改代理，只修改传入的字段
+func (a *App) UpdateProxy(name string, patch message.ProxyPatch) (message.ProxyMsgVo, error) {
+	if err := checkStore(); err != nil {
+		return message.ProxyMsgVo{}, err
+	}
+	return proxies.Patch(guiActor, name, patch)
+}
+
+// StartProtocolTest 启动 PROXY 协议测试监听
+func (a *App) StartProtocolTest(name string) (message.ProtocolTest, error) {
+	if err := checkStore(); err != nil {
+		return message.ProtocolTest{}, err
+	}
+	return proxies.StartProtocolTest(name)
+}
+
+// GetProtocolTest 获取 PROXY 协议测试监听状态
+func (a *App) GetProtocolTest(name string) (message.ProtocolTest, error) {
+	if err := checkStore(); err != nil {
+		return message.ProtocolTest{}, err
+	}
+	return proxies.ProtocolTest(name)
+}
+
+// StopProtocolTest 停止 PROXY 协议测试监听
+func (a *App) StopProtocolTest(name string) error {
+	if err := checkStore(); err != nil {
+		return err
+	}
+	proxies.StopProtocolTest(name)
+	return nil
+}
+
+// GetProxyStats 获取代理流量统计，window 为时间序列时长，如 1h，为空时返回全部
+func (a *App) GetProxyStats(name string, window string) (message.ProxyStats, error) {
+	if err := checkStore(); err != nil {
+		return message.ProxyStats{}, err
+	}
+	d, err := parseStatsWindow(window)
+	if err != nil {
+		return message.ProxyStats{}, err
+	}
+	return proxies.Stats(name, d)
+}
+
+// GetLogs 查询最近的日志，level 为最低级别，proxy 为空时不按代理过滤，limit 为 0 时返回全部
+func (a *App) GetLogs(level string, proxy string, limit int) ([]message.LogEntry, error) {
+	filter, err := parseLogFilter(url.Values{
+		"level": {level},
+		"proxy": {proxy},
+		"limit": {strconv.Itoa(limit)},
+	}, defaultLogLimit)
+	if err != nil {
+		return nil, err
+	}
+	return logger.Query(filter), nil
+}
+
+// GetAuditRecords 查询配置变更审计记录，参数为空时不过滤，limit 为 0 时返回全部
+func (a *App) GetAuditRecords(action string, target string, source string, limit int) ([]message.AuditRecord, error) {
+	if err := checkStore(); err != nil {
+		return nil, err
+	}
+	return audit.Query(service.AuditFilter{Action: action, Target: target, Source: source, Limit: limit})
+}
+
+// ListGroups 获取负载均衡组列表
+func (a *App) ListGroups() ([]message.ProxyGroup, error) {
+	if err := checkStore(); err != nil {
+		return nil, err
+	}
+	return proxies.Groups(), nil
+}
+
+// DeleteProxy 删除代理
+func (a *App) DeleteProxy(name string) error {
+	if err := checkStore(); err != nil {
+		return err
+	}
+	return proxies.Delete(guiActor, name)
+}
+
+// SetProxyEnabled 开启或关闭代理
+func (a *App) SetProxyEnabled(name string, enabled bool) (message.ProxyMsgVo, error) {
+	if err := checkStore(); err != nil {
+		return message.ProxyMsgVo{}, err
+	}
+	return proxies.SetEnabled(guiActor, name, enabled)
+}
+
+// EnableProxyWithExpiry 开启代理并设置到期，到期后按到期操作关闭或删除
+func (a *App) EnableProxyWithExpiry(name string, expiry message.ProxyExpiry) (message.ProxyMsgVo, error) {
+	if err := checkStore(); err != nil {
+		return message.ProxyMsgVo{}, err
+	}
+	return proxies.EnableWithExpiry(guiActor, name, expiry)
+}
+
+// Connect 连接服务器
+func (a *App) Connect(serverInfo message.ConnectServerMsg) (message.ServiceInfo, error) {
+	if err := checkStore(); err != nil {
+		return message.ServiceInfo{}, err
+	}
+	if err := connection.Connect(guiActor, serverInfo); err != nil {
+		return message.ServiceInfo{}, err
+	}
+	return connection.Info(), nil
+}
+
+// Disconnect 断开服务器并解锁配置
+func (a *App) Disconnect() (message.ServiceInfo, error) {
+	if err := checkStore(); err != nil {
+		return message.ServiceInfo{}, err
+	}
+	if err := connection.Disconnect(guiActor); err != nil {
+		return message.ServiceInfo{}, err
+	}
+	return connection.Info(), nil
+}
+
+// GetServerInfo 获取服务器连接状态
+func (a *App) GetServerInfo() (message.ServiceInfo, error) {
+	if err := checkStore(); err != nil {
+		return message.ServiceInfo{}, err
+	}
+	return connection.Info(), nil
+}
+
+// FindRemotePort 查找空闲的远程端口
+func (a *App) FindRemotePort(proxyType string) (message.FreePort, error) {
+	if err := checkStore(); err != nil {
+		return message.FreePort{}, err
+	}
+	return proxies.FreeRemotePort(proxyType)
 }
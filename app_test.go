@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/douguohai/frp-client/message"
+)
+
+// 存储打开失败时服务未初始化，绑定方法返回存储错误而不是空指针异常
+func TestAppStoreUnavailable(t *testing.T) {
+	storeErr = errors.New("permission denied")
+	defer func() { storeErr = nil }()
+
+	a := NewApp()
+	calls := map[string]func() error{
+		"SetFrpServiceConfig": func() error { _, err := a.SetFrpServiceConfig("127.0.0.1", 7000); return err },
+		"GetDashboardInfo":    func() error { _, err := a.GetDashboardInfo(); return err },
+		"ListProxies":         func() error { _, err := a.ListProxies(); return err },
+		"AddProxy":            func() error { _, err := a.AddProxy(message.ProxyMsg{ProxyName: "web"}); return err },
+		"UpdateProxy":         func() error { _, err := a.UpdateProxy("web", message.ProxyPatch{}); return err },
+		"StartProtocolTest":   func() error { _, err := a.StartProtocolTest("web"); return err },
+		"GetProtocolTest":     func() error { _, err := a.GetProtocolTest("web"); return err },
+		"StopProtocolTest":    func() error { return a.StopProtocolTest("web") },
+		"GetProxyStats":       func() error { _, err := a.GetProxyStats("web", ""); return err },
+		"GetAuditRecords":     func() error { _, err := a.GetAuditRecords("", "", "", 0); return err },
+		"ListGroups":          func() error { _, err := a.ListGroups(); return err },
+		"DeleteProxy":         func() error { return a.DeleteProxy("web") },
+		"SetProxyEnabled":     func() error { _, err := a.SetProxyEnabled("web", true); return err },
+		"EnableProxyWithExpiry": func() error {
+			_, err := a.EnableProxyWithExpiry("web", message.ProxyExpiry{ExpireIn: "1h"})
+			return err
+		},
+		"Connect":        func() error { _, err := a.Connect(message.ConnectServerMsg{}); return err },
+		"Disconnect":     func() error { _, err := a.Disconnect(); return err },
+		"GetServerInfo":  func() error { _, err := a.GetServerInfo(); return err },
+		"FindRemotePort": func() error { _, err := a.FindRemotePort("tcp"); return err },
+	}
+	for name, call := range calls {
+		if err := call(); !errors.Is(err, errStoreUnavailable) {
+			t.Errorf("%s 返回 %v，期望存储不可用", name, err)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/douguohai/frp-client/message"
+	"github.com/douguohai/frp-client/service"
+)
+
+// 审计记录导出格式
+const (
+	auditFormatJSON  = "json"
+	auditFormatCSV   = "csv"
+	auditFormatJSONL = "jsonl"
+)
+
+// auditHandler GET /api/audit?action=&target=&source=&since=&until=&limit=&format=json|csv|jsonl
+// json 为统一响应，csv 和 jsonl 以附件下载
+func auditHandler(writer http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+	filter, err := parseAuditFilter(query)
+	if err != nil {
+		writeAPIError(writer, err)
+		return
+	}
+	format := query.Get("format")
+	switch format {
+	case "", auditFormatJSON, auditFormatCSV, auditFormatJSONL:
+	default:
+		writeAPIError(writer, newAPIError(http.StatusBadRequest, message.CodeInvalidRequest, fmt.Sprintf("不支持的导出格式 %q，可选 json、csv、jsonl", format)))
+		return
+	}
+
+	records, err := audit.Query(filter)
+	if err != nil {
+		writeAPIError(writer, err)
+		return
+	}
+
+	fileName := fmt.Sprintf("frp-client-audit-%s.%s", time.Now().Format("20060102150405"), format)
+	switch format {
+	case auditFormatCSV:
+		writer.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		writer.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+		writeAuditCSV(writer, records)
+	case auditFormatJSONL:
+		writer.Header().Set("Content-Type", "application/x-ndjson")
+		writer.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+		enc := json.NewEncoder(writer)
+		for _, r := range records {
+			enc.Encode(r)
+		}
+	default:
+		writeAPIData(writer, http.StatusOK, message.AuditRecords{Items: records})
+	}
+}
+
+// writeAuditCSV 配置以 JSON 字符串写入 before、after 两列，带 BOM 便于表格软件识别中文
+func writeAuditCSV(writer http.ResponseWriter, records []message.AuditRecord) {
+	writer.Write([]byte("\xef\xbb\xbf"))
+	w := csv.NewWriter(writer)
+	w.Write([]string{"id", "time", "action", "target", "source", "client", "remoteAddr", "before", "after", "err"})
+	for _, r := range records {
+		w.Write([]string{
+			r.ID,
+			time.Unix(0, r.Time).Format(time.RFC3339Nano),
+			r.Action,
+			r.Target,
+			r.Source,
+			r.Client,
+			r.RemoteAddr,
+			auditJSON(r.Before),
+			auditJSON(r.After),
+			r.Err,
+		})
+	}
+	w.Flush()
+}
+
+func auditJSON(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// parseAuditFilter 解析审计查询条件，since、until 为纳秒时间戳
+func parseAuditFilter(query url.Values) (service.AuditFilter, error) {
+	filter := service.AuditFilter{
+		Action: query.Get("action"),
+		Target: query.Get("target"),
+		Source: query.Get("source"),
+	}
+	for name, v := range map[string]*int64{"since": &filter.Since, "until": &filter.Until} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return filter, newAPIError(http.StatusBadRequest, message.CodeInvalidRequest, fmt.Sprintf("%s %q 应为纳秒时间戳", name, value))
+		}
+		*v = n
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			return filter, newAPIError(http.StatusBadRequest, message.CodeInvalidRequest, fmt.Sprintf("limit %q 应为非负整数", limit))
+		}
+		filter.Limit = n
+	}
+	return filter, nil
+}
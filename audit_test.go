@@ -0,0 +1,100 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/douguohai/frp-client/message"
+	"github.com/douguohai/frp-client/service"
+)
+
+// TestAudit 通过接口增删改代理，检查审计记录的操作、来源、前后配置及导出
+func TestAudit(t *testing.T) {
+	e := integration(t)
+	const name = "test-audit"
+	port, editPort := freePort(t), freePort(t)
+	groupKey := "test-secret"
+	e.addProxy(t, message.ProxyMsg{
+		ProxyName:  name,
+		LocalPort:  e.echoPort,
+		RemotePort: port,
+		Group:      "test-audit",
+		GroupKey:   groupKey,
+	})
+	for _, c := range []struct {
+		method, path string
+		body         interface{}
+	}{
+		{"POST", "/api/v1/proxies/" + name + "/enable", nil},
+		{"PATCH", "/api/v1/proxies/" + name, message.ProxyPatch{RemotePort: &editPort}},
+		{"POST", "/api/v1/proxies/" + name + "/disable", nil},
+		{"DELETE", "/api/v1/proxies/" + name, nil},
+	} {
+		e.call(t, c.method, c.path, c.body, nil)
+	}
+
+	var records message.AuditRecords
+	e.call(t, "GET", "/api/v1/audit?target="+name, nil, &records)
+	var actions []string
+	for _, r := range records.Items {
+		actions = append(actions, r.Action)
+		if r.Source != message.AuditSourceAPI || r.RemoteAddr == "" {
+			t.Fatalf("%s 的来源为 %+v", r.Action, r.AuditActor)
+		}
+	}
+	want := []string{
+		message.AuditActionAdd, message.AuditActionEnable, message.AuditActionEdit,
+		message.AuditActionDisable, message.AuditActionDelete,
+	}
+	if strings.Join(actions, ",") != strings.Join(want, ",") {
+		t.Fatalf("审计操作为 %v，应为 %v", actions, want)
+	}
+	edit := records.Items[2]
+	before, _ := edit.Before.(map[string]interface{})
+	after, _ := edit.After.(map[string]interface{})
+	if before["remotePort"] != float64(port) || after["remotePort"] != float64(editPort) {
+		t.Fatalf("编辑记录的前后配置为 %v -> %v", edit.Before, edit.After)
+	}
+	if after["groupKey"] != "******" {
+		t.Fatalf("审计记录未隐藏组密钥: %v", after["groupKey"])
+	}
+	e.call(t, "GET", "/api/v1/audit?action=edit&target="+name+"&limit=1", nil, &records)
+	if len(records.Items) != 1 || records.Items[0].ID != edit.ID {
+		t.Fatalf("按操作过滤返回 %+v", records.Items)
+	}
+	e.callFails(t, "GET", "/api/v1/audit?format=xml", nil)
+
+	for format, lines := range map[string]int{"csv": len(want) + 1, "jsonl": len(want)} {
+		resp, err := e.request("GET", "/api/v1/audit?target="+name+"&format="+format, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body := strings.TrimSpace(string(raw))
+		if resp.StatusCode != http.StatusOK || !strings.Contains(resp.Header.Get("Content-Disposition"), "attachment") {
+			t.Fatalf("导出 %s: %d %s", format, resp.StatusCode, resp.Header.Get("Content-Disposition"))
+		}
+		if n := len(strings.Split(body, "\n")); n != lines {
+			t.Fatalf("导出 %s 共 %d 行，应为 %d 行", format, n, lines)
+		}
+		if strings.Contains(body, groupKey) {
+			t.Fatalf("导出 %s 包含组密钥", format)
+		}
+	}
+
+	files, err := readStoreFiles(storeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name := range files {
+		if strings.HasPrefix(name, service.AuditCollection+"/") {
+			t.Fatalf("备份包含审计记录 %s", name)
+		}
+	}
+}
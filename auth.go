@@ -0,0 +1,193 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/douguohai/frp-client/logger"
+	"github.com/douguohai/frp-client/message"
+	"github.com/douguohai/frp-client/utils"
+)
+
+const (
+	// apiTokenFile 数据目录下保存接口令牌的文件，供命令行或脚本调用 /api
+	apiTokenFile = "api_token"
+
+	sessionCookieName = "frp_client_session"
+	// csrfCookieName / csrfHeaderName 与 axios 默认的 xsrf 配置一致，前端无需额外处理
+	csrfCookieName = "XSRF-TOKEN"
+	csrfHeaderName = "X-XSRF-TOKEN"
+)
+
+var (
+	// apiToken 每个安装固定的接口令牌
+	apiToken string
+
+	// sessionToken / csrfToken 每次启动随机生成，只用于界面
+	sessionToken = utils.RandomHex(32)
+	csrfToken    = utils.RandomHex(32)
+
+	// allowedHosts 允许访问接口的 Host，防止 DNS rebinding
+	allowedHosts = map[string]bool{
+		"localhost":       true,
+		"127.0.0.1":       true,
+		"::1":             true,
+		"wails":           true,
+		"wails.localhost": true,
+	}
+
+	// allowedOrigins 界面页面所在的 Origin：wails 内置地址和 wails dev 默认地址
+	// 与请求同源的 Origin 同样允许，其余本机端口上的页面不能调用接口
+	allowedOrigins = map[string]bool{
+		"wails://wails":           true,
+		"http://wails.localhost":  true,
+		"https://wails.localhost": true,
+		"http://localhost:34115":  true,
+	}
+
+	// csrfGETPaths 有副作用的旧 GET 接口，使用会话 cookie 时同样校验 csrf 头
+	csrfGETPaths = map[string]bool{
+		"/api/unlock": true,
+	}
+)
+
+// loadAPIToken 读取数据目录下的接口令牌，不存在时生成
+func loadAPIToken() error {
+	p := filepath.Join(storeDir, apiTokenFile)
+	data, err := os.ReadFile(p)
+	if err == nil && len(strings.TrimSpace(string(data))) > 0 {
+		apiToken = strings.TrimSpace(string(data))
+		return nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	apiToken = utils.RandomHex(32)
+	if err := os.WriteFile(p, []byte(apiToken+"\n"), 0600); err != nil {
+		return err
+	}
+	logger.Info("已生成接口令牌", "path", p)
+	return nil
+}
+
+// issueSession 加载界面时下发会话和 csrf cookie
+func issueSession(writer http.ResponseWriter) {
+	http.SetCookie(writer, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    sessionToken,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.SetCookie(writer, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    csrfToken,
+		Path:     "/",
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// apiAuthMiddleware 校验 /api 请求
+// 1. Host 必须是本机或 wails 内置地址，Origin 必须是界面所在地址或与请求同源
+// 2. 需要携带接口令牌（Authorization: Bearer）或界面会话 cookie
+// 3. 使用会话 cookie 的修改类请求需要携带 csrf 头
+func apiAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if err := checkRequestAuth(request); err != nil {
+			logger.Warn("拒绝请求", "method", request.Method, "path", request.URL.Path, "err", err)
+			writeAPIError(writer, err)
+			return
+		}
+		next.ServeHTTP(writer, request)
+	})
+}
+
+func checkRequestAuth(request *http.Request) error {
+	if !allowedHost(request.Host) {
+		return newAPIError(http.StatusForbidden, message.CodeForbidden, "非法的 Host")
+	}
+	if origin := request.Header.Get("Origin"); origin != "" && !allowedOrigin(request, origin) {
+		return newAPIError(http.StatusForbidden, message.CodeForbidden, "非法的 Origin")
+	}
+
+	if token := bearerToken(request); token != "" {
+		if apiToken != "" && secureEqual(token, apiToken) {
+			return nil
+		}
+		return newAPIError(http.StatusUnauthorized, message.CodeUnauthorized, "接口令牌无效")
+	}
+
+	cookie, err := request.Cookie(sessionCookieName)
+	if err != nil || !secureEqual(cookie.Value, sessionToken) {
+		return newAPIError(http.StatusUnauthorized, message.CodeUnauthorized, "未登录或会话已失效")
+	}
+
+	switch request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		if !csrfGETPaths[request.URL.Path] {
+			return nil
+		}
+	}
+	if !secureEqual(request.Header.Get(csrfHeaderName), csrfToken) {
+		return newAPIError(http.StatusForbidden, message.CodeForbidden, "csrf 校验失败")
+	}
+	return nil
+}
+
+// allowedHost 判断 host（可带端口）是否为允许的本地地址
+func allowedHost(hostport string) bool {
+	host := hostport
+	if h, _, err := net.SplitHostPort(hostport); err == nil {
+		host = h
+	}
+	host = strings.Trim(strings.ToLower(host), "[]")
+	return allowedHosts[host]
+}
+
+// allowedOrigin 按 scheme://host:port 整体比较 Origin
+func allowedOrigin(request *http.Request, origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	origin = strings.ToLower(u.Scheme + "://" + u.Host)
+	if allowedOrigins[origin] {
+		return true
+	}
+	scheme := "http"
+	if request.TLS != nil {
+		scheme = "https"
+	}
+	return origin == strings.ToLower(scheme+"://"+request.Host)
+}
+
+// requestActor 请求的操作来源，携带接口令牌的为 api，其余为界面
+func requestActor(request *http.Request) message.AuditActor {
+	source := message.AuditSourceGUI
+	if bearerToken(request) != "" {
+		source = message.AuditSourceAPI
+	}
+	return message.AuditActor{
+		Source:     source,
+		Client:     request.UserAgent(),
+		RemoteAddr: request.RemoteAddr,
+	}
+}
+
+func bearerToken(request *http.Request) string {
+	auth := request.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
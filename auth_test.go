@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckRequestAuth(t *testing.T) {
+	saved := apiToken
+	apiToken = "test-token"
+	defer func() { apiToken = saved }()
+
+	session := &http.Cookie{Name: sessionCookieName, Value: sessionToken}
+	cases := []struct {
+		name    string
+		method  string
+		path    string
+		host    string
+		origin  string
+		token   string
+		cookie  bool
+		csrf    bool
+		allowed bool
+	}{
+		{name: "无令牌和会话", method: "GET", path: "/api/getProxy"},
+		{name: "错误令牌", method: "GET", path: "/api/getProxy", token: "wrong"},
+		{name: "接口令牌", method: "POST", path: "/api/addProxy", token: "test-token", allowed: true},
+		{name: "会话读取", method: "GET", path: "/api/getProxy", cookie: true, allowed: true},
+		{name: "非本机 Host", method: "GET", path: "/api/getProxy", host: "evil.example:34115", token: "test-token"},
+		{name: "外部 Origin", method: "GET", path: "/api/getProxy", origin: "http://evil.example", token: "test-token"},
+		{name: "本机其他端口的 Origin", method: "POST", path: "/api/addProxy", origin: "http://localhost:8080", cookie: true, csrf: true},
+		{name: "同源的 Origin", method: "POST", path: "/api/addProxy", host: "127.0.0.1:9000", origin: "http://127.0.0.1:9000", cookie: true, csrf: true, allowed: true},
+		{name: "wails Origin", method: "POST", path: "/api/addProxy", host: "wails", origin: "wails://wails", cookie: true, csrf: true, allowed: true},
+		{name: "wails dev Origin", method: "POST", path: "/api/addProxy", origin: "http://localhost:34115", cookie: true, csrf: true, allowed: true},
+		{name: "POST 缺少 csrf", method: "POST", path: "/api/addProxy", cookie: true},
+		{name: "PUT 缺少 csrf", method: "PUT", path: "/api/openProxy", cookie: true},
+		{name: "DELETE 缺少 csrf", method: "DELETE", path: "/api/v1/proxies/web", cookie: true},
+		{name: "旧版 GET 断开缺少 csrf", method: "GET", path: "/api/unlock", cookie: true},
+		{name: "旧版 GET 断开携带 csrf", method: "GET", path: "/api/unlock", cookie: true, csrf: true, allowed: true},
+		{name: "POST 断开", method: "POST", path: "/api/unlock", cookie: true, csrf: true, allowed: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			request := httptest.NewRequest(c.method, c.path, nil)
+			request.Host = "localhost:34115"
+			if c.host != "" {
+				request.Host = c.host
+			}
+			if c.origin != "" {
+				request.Header.Set("Origin", c.origin)
+			}
+			if c.token != "" {
+				request.Header.Set("Authorization", "Bearer "+c.token)
+			}
+			if c.cookie {
+				request.AddCookie(session)
+			}
+			if c.csrf {
+				request.Header.Set(csrfHeaderName, csrfToken)
+			}
+			err := checkRequestAuth(request)
+			if (err == nil) != c.allowed {
+				t.Fatalf("allowed=%v, err=%v", c.allowed, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,476 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/douguohai/frp-client/logger"
+	"github.com/douguohai/frp-client/message"
+	"github.com/douguohai/frp-client/service"
+	"github.com/fatedier/frp/pkg/util/version"
+)
+
+const (
+	// appVersion 客户端版本，与 wails.json 中 productVersion 保持一致
+	appVersion = "1.0.0"
+
+	// backupFormatVersion 备份包格式版本，格式不兼容时递增
+	backupFormatVersion = 1
+
+	backupManifestName = "manifest.json"
+	backupStorePrefix  = "store/"
+
+	// backupMaxSize 恢复时允许上传的备份包大小上限
+	backupMaxSize = 64 << 20
+	// backupMaxUnpacked 备份包解压后的总大小上限，按实际读取的字节数计
+	backupMaxUnpacked = 256 << 20
+	// backupMaxEntries 备份包的文件数上限
+	backupMaxEntries = 10000
+
+	restoreModeOverwrite = "overwrite"
+	restoreModeMerge     = "merge"
+)
+
+// backupHandler 导出全部配置为 zip 备份包
+func backupHandler(writer http.ResponseWriter, request *http.Request) {
+	buf := &bytes.Buffer{}
+	if err := writeBackup(buf); err != nil {
+		logger.Error("生成备份失败", "err", err)
+		writeAPIError(writer, newAPIError(http.StatusInternalServerError, message.CodeInternal, "生成备份失败"))
+		return
+	}
+
+	fileName := fmt.Sprintf("frp-client-backup-%s.zip", time.Now().Format("20060102150405"))
+	writer.Header().Set("Content-Type", "application/zip")
+	writer.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	writer.Write(buf.Bytes())
+}
+
+// restoreHandler 从备份包恢复配置
+// mode=overwrite 覆盖当前存储（默认），mode=merge 合并到当前存储
+// dryRun=true 只校验并返回变更报告，不写入
+// 备份不含 dashboard 密码，multipart 上传时可通过 dashboardPassword 字段提供
+func restoreHandler(writer http.ResponseWriter, request *http.Request) {
+	request.Body = http.MaxBytesReader(writer, request.Body, backupMaxSize)
+
+	var (
+		data     []byte
+		password string
+		err      error
+	)
+	if strings.HasPrefix(request.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, ferr := request.FormFile("file")
+		if ferr != nil {
+			writeAPIError(writer, errReadBackup)
+			return
+		}
+		defer file.Close()
+		password = request.FormValue("dashboardPassword")
+		data, err = io.ReadAll(file)
+	} else {
+		data, err = io.ReadAll(request.Body)
+	}
+	if err != nil {
+		writeAPIError(writer, errReadBackup)
+		return
+	}
+
+	mode := request.URL.Query().Get("mode")
+	if mode == "" {
+		mode = restoreModeOverwrite
+	}
+	dryRun := request.URL.Query().Get("dryRun") == "true"
+
+	report, err := restoreBackup(data, mode, dryRun, password)
+	if err != nil {
+		logger.Warn("恢复备份失败", "mode", mode, "dryRun", dryRun, "err", err)
+		writeAPIError(writer, err)
+		return
+	}
+
+	logger.Info("恢复备份", "mode", report.Mode, "dryRun", dryRun,
+		"added", len(report.Added), "updated", len(report.Updated), "removed", len(report.Removed))
+	if !dryRun {
+		audit.Record(requestActor(request), message.AuditActionRestore, report.Mode, nil, map[string]interface{}{
+			"added":         report.Added,
+			"updated":       report.Updated,
+			"removed":       report.Removed,
+			"serverApplied": report.ServerApplied,
+			"previousStore": report.PreviousStore,
+		}, nil)
+	}
+	writeAPIData(writer, http.StatusOK, report)
+}
+
+var errReadBackup = newAPIError(http.StatusBadRequest, message.CodeInvalidRequest, "读取备份文件失败")
+
+// invalidBackup 备份包校验失败
+func invalidBackup(format string, args ...interface{}) error {
+	return newAPIError(http.StatusUnprocessableEntity, message.CodeValidationFailed, fmt.Sprintf(format, args...))
+}
+
+// writeBackup 将存储目录和元数据写入 zip，dashboard 密码不写入备份
+func writeBackup(w io.Writer) error {
+	files, err := readStoreFiles(storeDir)
+	if err != nil {
+		return err
+	}
+
+	server := connection.Server()
+	server.DashboardPassword = ""
+	manifest := message.BackupManifest{
+		FormatVersion: backupFormatVersion,
+		AppVersion:    appVersion,
+		FrpVersion:    version.Full(),
+		CreateTime:    time.Now().UnixNano(),
+		Server:        server,
+		Files:         sortedKeys(files),
+	}
+
+	zw := zip.NewWriter(w)
+	mw, err := zw.Create(backupManifestName)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(mw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return err
+	}
+
+	for _, name := range manifest.Files {
+		fw, err := zw.Create(backupStorePrefix + name)
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(files[name]); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// restoreBackup 校验备份包并替换或合并当前存储
+// 未设置服务器时应用备份中的服务器配置，dashboard 密码为空时保持当前密码
+func restoreBackup(data []byte, mode string, dryRun bool, dashboardPassword string) (message.RestoreReport, error) {
+	report := message.RestoreReport{
+		Mode:   mode,
+		DryRun: dryRun,
+	}
+	if mode != restoreModeOverwrite && mode != restoreModeMerge {
+		return report, newAPIError(http.StatusBadRequest, message.CodeInvalidRequest, "不支持的恢复模式: "+mode)
+	}
+
+	manifest, files, err := readBackup(data)
+	if err != nil {
+		return report, err
+	}
+	report.Manifest = manifest
+
+	current, err := readStoreFiles(storeDir)
+	if err != nil {
+		return report, err
+	}
+
+	target := files
+	if mode == restoreModeMerge {
+		target = make(map[string][]byte, len(current)+len(files))
+		for name, content := range current {
+			target[name] = content
+		}
+		for name, content := range files {
+			target[name] = content
+		}
+	}
+
+	for _, name := range sortedKeys(target) {
+		old, has := current[name]
+		switch {
+		case !has:
+			report.Added = append(report.Added, recordName(name))
+		case !bytes.Equal(old, target[name]):
+			report.Updated = append(report.Updated, recordName(name))
+		default:
+			report.Unchanged = append(report.Unchanged, recordName(name))
+		}
+	}
+	for _, name := range sortedKeys(current) {
+		if _, has := target[name]; !has {
+			report.Removed = append(report.Removed, recordName(name))
+		}
+	}
+
+	if dryRun {
+		return report, nil
+	}
+
+	// 替换期间不能有代理状态同步或定时任务写入旧目录
+	err = proxies.Exclusive(func() (err error) {
+		report.PreviousStore, err = replaceStore(target)
+		return err
+	})
+	if err != nil {
+		return report, err
+	}
+
+	if !connection.Configured() && manifest.Server.ServerIp != "" {
+		server := manifest.Server
+		server.DashboardPassword = dashboardPassword
+		connection.SetServer(server)
+		report.ServerApplied = true
+	}
+
+	proxies.Reload()
+	return report, nil
+}
+
+// readBackup 解析并校验备份包
+func readBackup(data []byte) (message.BackupManifest, map[string][]byte, error) {
+	manifest := message.BackupManifest{}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return manifest, nil, invalidBackup("备份文件格式错误")
+	}
+
+	// 先按声明的大小检查，声明可能不实，读取时再按实际字节数限制
+	if len(zr.File) > backupMaxEntries {
+		return manifest, nil, invalidBackup("备份包含 %d 个文件，超过上限 %d", len(zr.File), backupMaxEntries)
+	}
+	var declared uint64
+	for _, f := range zr.File {
+		declared += f.UncompressedSize64
+	}
+	if declared > backupMaxUnpacked {
+		return manifest, nil, invalidBackup("备份解压后超过 %d MB", backupMaxUnpacked>>20)
+	}
+
+	hasManifest := false
+	files := map[string][]byte{}
+	remaining := int64(backupMaxUnpacked)
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		content, err := readZipFile(f, remaining)
+		if err == errBackupTooLarge {
+			return manifest, nil, invalidBackup("备份解压后超过 %d MB", backupMaxUnpacked>>20)
+		}
+		if err != nil {
+			return manifest, nil, invalidBackup("读取备份内容失败: %s", f.Name)
+		}
+		remaining -= int64(len(content))
+
+		if f.Name == backupManifestName {
+			if err := json.Unmarshal(content, &manifest); err != nil {
+				return manifest, nil, invalidBackup("备份元数据解析失败")
+			}
+			// 旧版本的备份包含 dashboard 密码，不使用也不返回
+			manifest.Server.DashboardPassword = ""
+			hasManifest = true
+			continue
+		}
+
+		name := strings.TrimPrefix(f.Name, backupStorePrefix)
+		if name == f.Name || !validStorePath(name) {
+			return manifest, nil, invalidBackup("备份包含非法路径: %s", f.Name)
+		}
+		if localStoreDir(name) {
+			continue
+		}
+		files[name] = content
+	}
+
+	if !hasManifest {
+		return manifest, nil, invalidBackup("备份缺少元数据")
+	}
+	if manifest.FormatVersion < 1 || manifest.FormatVersion > backupFormatVersion {
+		return manifest, nil, invalidBackup("不支持的备份格式版本: %d", manifest.FormatVersion)
+	}
+
+	for name, content := range files {
+		if err := validateStoreRecord(name, content); err != nil {
+			return manifest, nil, err
+		}
+	}
+	return manifest, files, nil
+}
+
+// validateStoreRecord 校验代理记录能被正常加载
+func validateStoreRecord(name string, content []byte) error {
+	dir, file := path.Split(name)
+	if dir != service.ProxyCollection+"/" {
+		return nil
+	}
+
+	proxy := message.ProxyMsg{}
+	if err := json.Unmarshal(content, &proxy); err != nil {
+		return invalidBackup("代理记录解析失败: %s", name)
+	}
+	if proxy.ProxyName == "" || proxy.ProxyName+".json" != file {
+		return invalidBackup("代理记录名称不一致: %s", name)
+	}
+	if _, err := service.ProxyConf(proxy); err != nil {
+		return invalidBackup("代理配置校验失败: %s", proxy.ProxyName)
+	}
+	return nil
+}
+
+// replaceStore 将文件写入临时目录后整体替换存储目录，旧目录保留为 .prev
+func replaceStore(files map[string][]byte) (string, error) {
+	tmpDir := fmt.Sprintf("%s.restore-%d", storeDir, time.Now().UnixNano())
+	for name, content := range files {
+		p := filepath.Join(tmpDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			os.RemoveAll(tmpDir)
+			return "", err
+		}
+		if err := os.WriteFile(p, content, 0644); err != nil {
+			os.RemoveAll(tmpDir)
+			return "", err
+		}
+	}
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		return "", err
+	}
+	if apiToken != "" {
+		if err := os.WriteFile(filepath.Join(tmpDir, apiTokenFile), []byte(apiToken+"\n"), 0600); err != nil {
+			os.RemoveAll(tmpDir)
+			return "", err
+		}
+	}
+
+	// 替换目录前关闭日志文件，替换后将本机目录移入新目录
+	logger.SetDir("")
+	defer func() {
+		if err := logger.SetDir(logDir()); err != nil {
+			logger.Warn("无法打开日志文件，日志只输出到控制台", "dir", logDir(), "err", err)
+		}
+	}()
+
+	prevDir := storeDir + ".prev"
+	if err := os.RemoveAll(prevDir); err != nil {
+		os.RemoveAll(tmpDir)
+		return "", err
+	}
+	if err := os.Rename(storeDir, prevDir); err != nil && !os.IsNotExist(err) {
+		os.RemoveAll(tmpDir)
+		return "", err
+	}
+	if err := os.Rename(tmpDir, storeDir); err != nil {
+		os.Rename(prevDir, storeDir)
+		os.RemoveAll(tmpDir)
+		return "", err
+	}
+	for _, dir := range localStoreDirs {
+		if err := os.Rename(filepath.Join(prevDir, dir), filepath.Join(storeDir, dir)); err != nil && !os.IsNotExist(err) {
+			logger.Warn("移动本机目录失败", "dir", dir, "err", err)
+		}
+	}
+
+	if err := store.Reopen(); err != nil {
+		return "", err
+	}
+	return prevDir, nil
+}
+
+// readStoreFiles 读取存储目录下全部文件，key 为以 / 分隔的相对路径
+func readStoreFiles(dir string) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && localStoreDir(filepath.ToSlash(rel)) {
+			return filepath.SkipDir
+		}
+		if !d.Type().IsRegular() || strings.HasSuffix(p, ".tmp") {
+			return nil
+		}
+		// 接口令牌属于本机，不随备份迁移
+		if rel == apiTokenFile {
+			return nil
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = content
+		return nil
+	})
+	return files, err
+}
+
+// errBackupTooLarge 备份解压后超过 backupMaxUnpacked
+var errBackupTooLarge = errors.New("备份解压后过大")
+
+// readZipFile 读取 zip 内的文件，超过 limit 字节时返回 errBackupTooLarge
+func readZipFile(f *zip.File, limit int64) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	content, err := io.ReadAll(io.LimitReader(rc, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > limit {
+		return nil, errBackupTooLarge
+	}
+	return content, nil
+}
+
+// localStoreDirs 属于本机的目录，不随备份迁移，恢复时保留：日志和只追加的审计记录
+var localStoreDirs = []string{logDirName, service.AuditCollection}
+
+// localStoreDir 是否位于本机目录内，按第一级目录判断，name 为以 / 分隔的相对路径
+func localStoreDir(name string) bool {
+	first := strings.SplitN(name, "/", 2)[0]
+	for _, local := range localStoreDirs {
+		if first == local {
+			return true
+		}
+	}
+	return false
+}
+
+// validStorePath 备份内路径必须是存储目录内的相对路径
+func validStorePath(name string) bool {
+	if name == "" || path.IsAbs(name) || strings.Contains(name, "\\") {
+		return false
+	}
+	return path.Clean(name) == name && name != ".." && !strings.HasPrefix(name, "../")
+}
+
+// recordName 报告中使用 collection/resource 形式
+func recordName(name string) string {
+	return strings.TrimSuffix(name, ".json")
+}
+
+func sortedKeys(m map[string][]byte) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
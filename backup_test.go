@@ -0,0 +1,175 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/douguohai/frp-client/message"
+	"github.com/douguohai/frp-client/service"
+)
+
+// zipBackup 按文件名和内容生成备份包
+func zipBackup(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(content)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// manifestJSON 备份元数据
+func manifestJSON(t *testing.T, manifest message.BackupManifest) []byte {
+	t.Helper()
+	if manifest.FormatVersion == 0 {
+		manifest.FormatVersion = backupFormatVersion
+	}
+	b, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// TestReadBackupDropsDashboardPassword 旧版本备份中的 dashboard 密码不使用也不返回
+func TestReadBackupDropsDashboardPassword(t *testing.T) {
+	data := zipBackup(t, map[string][]byte{
+		backupManifestName: manifestJSON(t, message.BackupManifest{Server: message.ConnectServerMsg{
+			ServerIp:          "1.2.3.4",
+			ServerPort:        7000,
+			DashboardUser:     "admin",
+			DashboardPassword: "old-secret",
+		}}),
+	})
+	manifest, _, err := readBackup(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Server.DashboardPassword != "" || manifest.Server.DashboardUser != "admin" {
+		t.Fatalf("服务器配置为 %+v", manifest.Server)
+	}
+}
+
+func TestBackupOmitsDashboardPassword(t *testing.T) {
+	e := integration(t)
+	resp, err := e.request("GET", "/api/v1/backup", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range zr.File {
+		content, err := readZipFile(f, backupMaxUnpacked)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(content), testDashboardPassword) {
+			t.Fatalf("%s 包含 dashboard 密码", f.Name)
+		}
+		if f.Name != backupManifestName {
+			continue
+		}
+		var manifest message.BackupManifest
+		if err := json.Unmarshal(content, &manifest); err != nil {
+			t.Fatal(err)
+		}
+		if manifest.Server.DashboardUser != testDashboardUser || manifest.Server.DashboardPassword != "" {
+			t.Fatalf("备份中的服务器配置为 %+v", manifest.Server)
+		}
+	}
+	if connection.Server().DashboardPassword != testDashboardPassword {
+		t.Fatal("备份修改了当前的 dashboard 密码")
+	}
+}
+
+// TestReadBackupSkipsLocalDirs 日志和审计目录及其子目录下的文件都不恢复
+func TestReadBackupSkipsLocalDirs(t *testing.T) {
+	data := zipBackup(t, map[string][]byte{
+		backupManifestName: manifestJSON(t, message.BackupManifest{}),
+		backupStorePrefix + service.AuditCollection + "/a.json":        []byte("{}"),
+		backupStorePrefix + service.AuditCollection + "/x/y.json":      []byte("{}"),
+		backupStorePrefix + logDirName + "/old/frp-client.log":         []byte("log"),
+		backupStorePrefix + service.StatsCollection + "/" + "web.json": []byte("{}"),
+	})
+	_, files, err := readBackup(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[service.StatsCollection+"/web.json"] == nil {
+		t.Fatalf("恢复的文件为 %v", sortedKeys(files))
+	}
+}
+
+// TestReadBackupLimits 文件数和解压后的总大小超过上限时不读取内容
+func TestReadBackupLimits(t *testing.T) {
+	manifest := manifestJSON(t, message.BackupManifest{})
+
+	many := map[string][]byte{backupManifestName: manifest}
+	for i := 0; i < backupMaxEntries; i++ {
+		many[fmt.Sprintf("%s%s/p%d.json", backupStorePrefix, service.StatsCollection, i)] = []byte("{}")
+	}
+	if _, _, err := readBackup(zipBackup(t, many)); err == nil || !strings.Contains(err.Error(), "个文件") {
+		t.Fatalf("文件过多时返回 %v", err)
+	}
+
+	// 声明的大小超过上限，内容本身很小
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	w, err := zw.Create(backupManifestName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(manifest)
+	for i := 0; i < 2; i++ {
+		if _, err := zw.CreateRaw(&zip.FileHeader{
+			Name:               fmt.Sprintf("%s%s/big%d.json", backupStorePrefix, service.StatsCollection, i),
+			Method:             zip.Store,
+			CompressedSize64:   2,
+			UncompressedSize64: backupMaxUnpacked / 2,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := readBackup(buf.Bytes()); err == nil || !strings.Contains(err.Error(), "MB") {
+		t.Fatalf("声明的大小过大时返回 %v", err)
+	}
+}
+
+// TestReadZipFileLimit 按实际读取的字节数限制，不依赖声明的大小
+func TestReadZipFileLimit(t *testing.T) {
+	data := zipBackup(t, map[string][]byte{"a": bytes.Repeat([]byte("x"), 100)})
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, err := readZipFile(zr.File[0], 100); err != nil || len(content) != 100 {
+		t.Fatalf("正好达到上限时返回 %d %v", len(content), err)
+	}
+	if _, err := readZipFile(zr.File[0], 99); err != errBackupTooLarge {
+		t.Fatalf("超过上限时返回 %v", err)
+	}
+}
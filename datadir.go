@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"html"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/douguohai/frp-client/logger"
+	"github.com/douguohai/frp-client/service"
+)
+
+const (
+	// dataDirEnv 指定数据目录的环境变量
+	dataDirEnv = "FRP_CLIENT_DATA_DIR"
+	// portableEnv 为 1 或 true 时启用便携模式
+	portableEnv = "FRP_CLIENT_PORTABLE"
+	// portableMarker 程序同级目录存在该文件时启用便携模式
+	portableMarker = "portable"
+
+	appDirName    = "frp-client"
+	legacyDirName = ".ftpStore"
+)
+
+var (
+	dataDirFlag  = flag.String("data-dir", "", "数据目录，优先级高于环境变量 "+dataDirEnv)
+	portableFlag = flag.Bool("portable", false, "便携模式，数据保存在程序同级的 data 目录")
+
+	// storeErr 存储打开失败的原因，非空时界面只展示错误页
+	storeErr error
+)
+
+// openStore 解析数据目录并打开本地存储
+func openStore() error {
+	dir, err := resolveDataDir()
+	if err != nil {
+		return err
+	}
+	storeDir = dir
+
+	if err := os.MkdirAll(storeDir, 0755); err != nil {
+		return fmt.Errorf("无法创建数据目录 %s: %w", storeDir, err)
+	}
+	if err := logger.SetDir(logDir()); err != nil {
+		logger.Warn("无法打开日志文件，日志只输出到控制台", "dir", logDir(), "err", err)
+	}
+	fileStore, err := service.NewFileStore(storeDir)
+	if err != nil {
+		return fmt.Errorf("无法打开数据目录 %s: %w", storeDir, err)
+	}
+	store = fileStore
+	logger.Info("已打开数据目录", "dir", storeDir)
+	return nil
+}
+
+// resolveDataDir 数据目录优先级：命令行参数 > 环境变量 > 便携模式 > 系统默认目录
+func resolveDataDir() (string, error) {
+	if *dataDirFlag != "" {
+		return filepath.Abs(*dataDirFlag)
+	}
+	if dir := os.Getenv(dataDirEnv); dir != "" {
+		return filepath.Abs(dir)
+	}
+	if isPortable() {
+		exeDir, err := executableDir()
+		if err != nil {
+			return "", fmt.Errorf("便携模式无法定位程序目录: %w", err)
+		}
+		return filepath.Join(exeDir, "data"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("无法获取用户目录，请通过 -data-dir 或 %s 指定数据目录: %w", dataDirEnv, err)
+	}
+
+	// 兼容旧版本的存储位置
+	legacy := filepath.Join(home, legacyDirName)
+	if _, err := os.Stat(legacy); err == nil || runtime.GOOS != "linux" {
+		return legacy, nil
+	}
+
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(xdg) {
+		return filepath.Join(xdg, appDirName), nil
+	}
+	return filepath.Join(home, ".config", appDirName), nil
+}
+
+// isPortable 是否启用便携模式
+func isPortable() bool {
+	if *portableFlag {
+		return true
+	}
+	switch os.Getenv(portableEnv) {
+	case "1", "true":
+		return true
+	}
+	exeDir, err := executableDir()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(filepath.Join(exeDir, portableMarker))
+	return err == nil
+}
+
+func executableDir() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	exe, err = filepath.EvalSymlinks(exe)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Dir(exe), nil
+}
+
+// errStoreUnavailable 存储未打开时接口返回的错误
+var errStoreUnavailable = errors.New("本地存储不可用")
+
+// checkStore 存储打开失败时各服务未初始化，wails 绑定方法先检查再调用服务
+func checkStore() error {
+	if storeErr != nil {
+		return fmt.Errorf("%w: %v", errStoreUnavailable, storeErr)
+	}
+	return nil
+}
+
+// writeStoreErrorPage 存储打开失败时的启动错误页
+func writeStoreErrorPage(writer http.ResponseWriter) {
+	writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+	writer.WriteHeader(http.StatusServiceUnavailable)
+	fmt.Fprintf(writer, `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>启动失败</title></head>
+<body style="font-family: sans-serif; color: #fff; background: #1b2636; padding: 40px;">
+<h2>无法打开本地数据目录</h2>
+<p>%s</p>
+<p>数据目录：<code>%s</code></p>
+<p>请检查目录权限，或通过以下方式指定其他目录后重新启动：</p>
+<ul>
+<li>命令行参数 <code>-data-dir &lt;目录&gt;</code></li>
+<li>环境变量 <code>%s</code></li>
+<li>命令行参数 <code>-portable</code>，或在程序同级目录创建 <code>%s</code> 文件，数据保存在程序同级的 data 目录</li>
+</ul>
+</body>
+</html>`, html.EscapeString(storeErr.Error()), html.EscapeString(storeDir), dataDirEnv, portableMarker)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/douguohai/frp-client/message"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// sseKeepAlive SSE 心跳间隔，避免连接被中间层断开
+const sseKeepAlive = 15 * time.Second
+
+var events = newEventHub()
+
+// eventHub 事件订阅中心
+type eventHub struct {
+	mu   sync.Mutex
+	subs map[chan message.Event]struct{}
+	// wailsCtx wails 运行时上下文，启动后用于推送前端事件
+	wailsCtx context.Context
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subs: map[chan message.Event]struct{}{}}
+}
+
+func (h *eventHub) subscribe() chan message.Event {
+	ch := make(chan message.Event, 64)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch
+}
+
+func (h *eventHub) unsubscribe(ch chan message.Event) {
+	h.mu.Lock()
+	delete(h.subs, ch)
+	h.mu.Unlock()
+}
+
+// setWailsContext 界面启动后设置，之后的事件同时推送给前端
+func (h *eventHub) setWailsContext(ctx context.Context) {
+	h.mu.Lock()
+	h.wailsCtx = ctx
+	h.mu.Unlock()
+}
+
+// publish 推送事件，订阅者处理不过来时丢弃，不阻塞业务
+func (h *eventHub) publish(e message.Event) {
+	h.mu.Lock()
+	for ch := range h.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+	ctx := h.wailsCtx
+	h.mu.Unlock()
+
+	if ctx != nil {
+		runtime.EventsEmit(ctx, e.Type, e)
+	}
+}
+
+// publishEvent 构建并推送事件
+func publishEvent(eventType string, body interface{}) {
+	events.publish(message.Event{
+		Type: eventType,
+		Time: time.Now().UnixNano(),
+		Body: body,
+	})
+}
+
+// eventPublisher 供 service 推送事件
+type eventPublisher struct{}
+
+func (eventPublisher) Publish(eventType string, body interface{}) {
+	publishEvent(eventType, body)
+}
+
+// eventsHandler 以 SSE 方式推送代理和连接状态变化
+func eventsHandler(writer http.ResponseWriter, request *http.Request) {
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		writeAPIError(writer, newAPIError(http.StatusInternalServerError, message.CodeInternal, "不支持事件推送"))
+		return
+	}
+
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.Header().Set("Connection", "keep-alive")
+
+	ch := events.subscribe()
+	defer events.unsubscribe(ch)
+
+	// 先推送一次当前连接状态，客户端无需再单独查询
+	writeSSE(writer, message.Event{
+		Type: message.EventConnection,
+		Time: time.Now().UnixNano(),
+		Body: connection.Info(),
+	})
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-request.Context().Done():
+			return
+		case e := <-ch:
+			writeSSE(writer, e)
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(writer, ": ping\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+func writeSSE(writer http.ResponseWriter, e message.Event) {
+	data, _ := json.Marshal(e)
+	fmt.Fprintf(writer, "event: %s\ndata: %s\n\n", e.Type, data)
+}
@@ -14,6 +14,7 @@ import { addRule, AlertComponent, render as renderAmis, ToastComponent } from 'a
 import { toast } from 'amis-ui';
 import Icon from './assert/4873dbfaf6a5.png'
 import { SetFrpServiceConfig } from "../wailsjs/go/main/App";
+import { EventsOn } from "../wailsjs/runtime";
 
 // amis 环境配置
 const env = {
@@ -99,6 +100,594 @@ const env = {
     // confirm,
 };
 
+// 支持的代理类型
+const proxyTypeOptions = [
+    {label: 'TCP', value: 'tcp'},
+    {label: 'UDP', value: 'udp'}
+];
+
+// 查找空闲的远程端口，结果写入表单的 remotePort
+const freePortApi = {
+    url: '/api/v1/server/free-port',
+    method: 'get',
+    data: {
+        type: '${type}'
+    },
+    adaptor: (payload: any) => ({...payload, data: payload.data ? {remotePort: payload.data.port} : {}})
+};
+
+// frps 返回的代理启动错误原因
+const proxyErrReasonMap = {
+    port_in_use: '远程端口已被占用',
+    port_not_allowed: '远程端口不在服务器允许范围内',
+    quota_exceeded: '超出服务器端口数量限制',
+    no_available_port: '服务器没有可分配的端口',
+    name_conflict: '服务器上已存在同名代理',
+    group_conflict: '组密钥或远程端口与服务器上的同组代理不一致',
+    health_check_failed: '健康检查失败，代理已下线',
+    unknown: '启动失败',
+};
+
+// frp 代理运行状态
+const proxyRunStatusMap = {
+    new: '<span class="label label-info">启动中</span>',
+    'wait start': '<span class="label label-info">等待启动</span>',
+    'start error': '<span class="label label-danger">启动失败</span>',
+    running: '<span class="label label-success">运行中</span>',
+    'check failed': '<span class="label label-warning">健康检查失败</span>',
+    closed: '<span class="label label-default">已关闭</span>',
+    '*': '<span class="label label-default">未知</span>'
+};
+
+// frp 健康检查结果
+const healthStatusMap = {
+    passed: '<span class="label label-success">通过</span>',
+    failed: '<span class="label label-danger">失败</span>'
+};
+
+// 本地服务探测状态，与 frp 代理状态分开展示
+const localStatusMap = {
+    up: '<span class="label label-success">正常</span>',
+    down: '<span class="label label-danger">无法访问</span>'
+};
+
+// frps dashboard 上的代理状态
+const serverStatusMap = {
+    online: '<span class="label label-success">在线</span>',
+    offline: '<span class="label label-default">离线</span>'
+};
+
+// frps dashboard 配置，用于查看服务器侧的代理状态和流量
+const dashboardFields = [
+    {
+        "type": "input-text",
+        "name": "dashboardUrl",
+        "label": "地址",
+        "placeholder": "http://1.2.3.4:7500"
+    },
+    {
+        "type": "input-text",
+        "name": "dashboardUser",
+        "label": "用户名",
+        "visibleOn": "${dashboardUrl}"
+    },
+    {
+        "type": "input-password",
+        "name": "dashboardPassword",
+        "label": "密码",
+        "placeholder": "留空保持原密码",
+        "visibleOn": "${dashboardUrl}"
+    }
+];
+
+// frps 服务器信息
+const dashboardDialog = {
+    "title": "frps 服务器信息",
+    "actions": [],
+    "body": {
+        "type": "service",
+        "api": "/api/v1/server/dashboard",
+        "body": {
+            "type": "property",
+            "column": 2,
+            "items": [
+                {"label": "版本", "content": "${version}"},
+                {"label": "在线客户端", "content": "${clientCounts}"},
+                {"label": "当前连接", "content": "${curConns}"},
+                {"label": "在线代理", "content": "tcp ${proxyTypeCounts.tcp || 0}，udp ${proxyTypeCounts.udp || 0}"},
+                {"label": "今日流入", "content": "${totalTrafficIn | bytes}"},
+                {"label": "今日流出", "content": "${totalTrafficOut | bytes}"}
+            ]
+        }
+    }
+};
+
+// frp 健康检查配置，连续失败后代理自动下线，恢复后重新上线
+const healthCheckFields = [
+    {
+        "type": "select",
+        "name": "healthCheck.type",
+        "label": "检查方式",
+        "clearable": true,
+        "placeholder": "不检查",
+        "options": [
+            {label: 'TCP 连接', value: 'tcp'},
+            {label: 'HTTP 请求', value: 'http'}
+        ]
+    },
+    {
+        "type": "input-text",
+        "name": "healthCheck.url",
+        "label": "检查路径",
+        "placeholder": "/health",
+        "description": "返回 200 视为正常",
+        "visibleOn": "${healthCheck.type == 'http'}",
+        "requiredOn": "${healthCheck.type == 'http'}"
+    },
+    {
+        "type": "input-number",
+        "name": "healthCheck.intervalS",
+        "label": "检查间隔（秒）",
+        "placeholder": "10",
+        "min": 0,
+        "max": 3600,
+        "visibleOn": "${healthCheck.type}"
+    },
+    {
+        "type": "input-number",
+        "name": "healthCheck.timeoutS",
+        "label": "超时（秒）",
+        "placeholder": "3",
+        "min": 0,
+        "max": 3600,
+        "visibleOn": "${healthCheck.type}"
+    },
+    {
+        "type": "input-number",
+        "name": "healthCheck.maxFailed",
+        "label": "连续失败次数",
+        "placeholder": "1",
+        "min": 0,
+        "max": 100,
+        "visibleOn": "${healthCheck.type}"
+    }
+];
+
+// 负载均衡组，多台机器上的同组代理共用远程端口，由 frps 轮流转发
+const groupFields = [
+    {
+        "type": "input-text",
+        "name": "group",
+        "label": "组名",
+        "description": "同组代理的远程端口和组密钥必须一致"
+    },
+    {
+        "type": "input-password",
+        "name": "groupKey",
+        "label": "组密钥",
+        "visibleOn": "${group}",
+        "description": "已设置的密钥不回显，组名不变时留空表示保持原密钥"
+    }
+];
+
+// PROXY 协议，frpc 连接本地服务时先发送协议头，本地服务可从中获取访问者的真实地址
+const proxyProtocolField = {
+    "type": "select",
+    "name": "proxyProtocolVersion",
+    "label": "PROXY 协议",
+    "clearable": true,
+    "placeholder": "不发送",
+    "visibleOn": "${type != 'udp'}",
+    "options": [
+        {"label": "v1", "value": "v1"},
+        {"label": "v2", "value": "v2"}
+    ],
+    "description": "本地服务需要支持 PROXY 协议，如 nginx 的 proxy_protocol"
+};
+
+// 流量统计，开启后由插件代替 frpc 连接本地服务
+const statsField = {
+    "type": "switch",
+    "name": "stats",
+    "label": "流量统计",
+    "visibleOn": "${type != 'udp'}",
+    "description": "统计经过代理的流量和连接数"
+};
+
+// 定时开关，范围内开启、范围外关闭，手动开关保持到下一次切换
+const scheduleFields = [
+    {
+        "type": "input-text",
+        "name": "schedule.windows",
+        "label": "时间窗口",
+        "placeholder": "mon-fri 09:00-18:00; sat 10:00-12:00",
+        "description": "多个窗口用分号分隔，星期可省略，结束早于开始时跨越午夜",
+        "disabledOn": "${schedule.cron}"
+    },
+    {
+        "type": "input-text",
+        "name": "schedule.cron",
+        "label": "cron 表达式",
+        "placeholder": "* 9-17 * * 1-5",
+        "description": "分 时 日 月 周，命中的分钟内开启，与时间窗口二选一",
+        "disabledOn": "${schedule.windows}"
+    },
+    {
+        "type": "input-text",
+        "name": "schedule.timezone",
+        "label": "时区",
+        "placeholder": "本机时区，如 Asia/Shanghai",
+        "visibleOn": "${schedule.windows || schedule.cron}"
+    }
+];
+
+// 到期操作
+const expireActionOptions = [
+    {label: '关闭', value: 'disable'},
+    {label: '删除', value: 'delete'}
+];
+
+// 临时代理，到期后自动关闭或删除，重启后仍然有效
+const expiryFields = [
+    {
+        "type": "input-text",
+        "name": "expireIn",
+        "label": "有效时长",
+        "placeholder": "30m、2h",
+        "description": "从保存时算起，留空保持原有到期时间"
+    },
+    {
+        "type": "select",
+        "name": "expireAction",
+        "label": "到期操作",
+        "placeholder": "关闭",
+        "clearable": true,
+        "options": expireActionOptions
+    }
+];
+
+// 临时开启，开启代理并设置有效时长
+const enableForDialog = {
+    "title": "临时开启",
+    "body": {
+        "type": "form",
+        "api": {
+            "method": "post",
+            "url": "/api/v1/proxies/${proxyName}/enable",
+            "data": {
+                "expireIn": "${expireIn}",
+                "expireAction": "${expireAction}"
+            }
+        },
+        "closeDialogOnSubmit": true,
+        "reload": "card-service-id",
+        "data": {"expireIn": "1h", "expireAction": ""},
+        "body": [
+            {
+                "type": "select",
+                "name": "expireIn",
+                "label": "有效时长",
+                "creatable": true,
+                "required": true,
+                "options": [
+                    {label: '15 分钟', value: '15m'},
+                    {label: '30 分钟', value: '30m'},
+                    {label: '1 小时', value: '1h'},
+                    {label: '2 小时', value: '2h'},
+                    {label: '8 小时', value: '8h'}
+                ]
+            },
+            {
+                "type": "select",
+                "name": "expireAction",
+                "label": "到期操作",
+                "placeholder": "关闭",
+                "clearable": true,
+                "options": expireActionOptions
+            }
+        ]
+    }
+};
+
+// PROXY 协议测试，暂时代替本地服务监听本地端口，解析收到的协议头，确认真实访问地址能否到达
+const protocolTestDialog = {
+    "title": "PROXY 协议测试",
+    "size": "lg",
+    "actions": [],
+    "body": [
+        {
+            "type": "tpl",
+            "tpl": "测试期间代替本地服务监听 127.0.0.1:${localPort}，请先停止本地服务，然后通过 ${remoteAddr} 访问，5 分钟后自动停止。"
+        },
+        {
+            "type": "button-toolbar",
+            "buttons": [
+                {
+                    "type": "button",
+                    "label": "开始测试",
+                    "level": "primary",
+                    "actionType": "ajax",
+                    "api": "post:/api/v1/proxies/${proxyName}/protocol-test",
+                    "reload": "protocol-test-crud"
+                },
+                {
+                    "type": "button",
+                    "label": "停止测试",
+                    "actionType": "ajax",
+                    "api": "delete:/api/v1/proxies/${proxyName}/protocol-test",
+                    "reload": "protocol-test-crud"
+                }
+            ]
+        },
+        {
+            "type": "crud",
+            "name": "protocol-test-crud",
+            "api": "/api/v1/proxies/${proxyName}/protocol-test",
+            "interval": 2000,
+            "silentPolling": true,
+            "headerToolbar": [
+                {
+                    "type": "tpl",
+                    "tpl": "${running ? '监听中' : '未在测试'}"
+                }
+            ],
+            "columns": [
+                {"name": "time", "label": "时间", "type": "tpl", "tpl": "${time / 1000000 | date:YYYY-MM-DD HH\\:mm\\:ss:x}"},
+                {"name": "version", "label": "协议版本", "type": "mapping", "map": {"0": "<span class='label label-danger'>未收到</span>", "*": "v${version}"}},
+                {"name": "sourceAddr", "label": "访问者地址"},
+                {"name": "destAddr", "label": "目标地址"},
+                {"name": "err", "label": "解析错误", "className": "text-danger"}
+            ]
+        }
+    ]
+};
+
+// 校验失败时接口在 data 中返回字段错误列表，转换为 amis 表单的 errors，在对应表单项下展示
+const fieldErrorAdaptor = (payload: any) => {
+    if (payload.status !== 0 && Array.isArray(payload.data)) {
+        const errors: { [field: string]: string } = {};
+        payload.data.forEach((e: any) => {
+            errors[e.field] = errors[e.field] ? errors[e.field] + '；' + e.msg : e.msg;
+        });
+        return {...payload, status: 422, errors};
+    }
+    return payload;
+};
+
+// 流量统计时间序列转换为 echarts 配置，采样时间为纳秒
+const statsChartAdaptor = (payload: any) => {
+    if (payload.status !== 0) {
+        return payload;
+    }
+    const rows = payload.data.rows || [];
+    const series = (name: string, key: string) => ({
+        name,
+        type: 'line',
+        showSymbol: false,
+        data: rows.map((p: any) => [p.time / 1e6, p[key]])
+    });
+    return {
+        ...payload,
+        data: {
+            tooltip: {trigger: 'axis'},
+            legend: {data: ['流入字节', '流出字节', '新建连接']},
+            xAxis: {type: 'time'},
+            yAxis: [{type: 'value', name: '字节'}, {type: 'value', name: '连接'}],
+            series: [
+                series('流入字节', 'bytesIn'),
+                series('流出字节', 'bytesOut'),
+                {...series('新建连接', 'newConns'), yAxisIndex: 1}
+            ]
+        }
+    };
+};
+
+// 代理流量统计，每分钟采样一次，保留 24 小时
+const statsDialog = {
+    "title": "流量统计",
+    "size": "lg",
+    "actions": [],
+    "body": {
+        "type": "service",
+        "api": "/api/v1/proxies/${proxyName}/stats?window=1m",
+        "interval": 5000,
+        "silentPolling": true,
+        "body": [
+            {
+                "type": "tpl",
+                "tpl": "当前连接 ${curConns}，累计连接 ${totalConns}，累计流入 ${bytesIn | bytes}，累计流出 ${bytesOut | bytes}"
+            },
+            {
+                "type": "chart",
+                "api": {
+                    "url": "/api/v1/proxies/${proxyName}/stats",
+                    "adaptor": statsChartAdaptor
+                },
+                "interval": 60000,
+                "height": 300
+            },
+            {
+                "type": "tpl",
+                "visibleOn": "${type == 'udp'}",
+                "tpl": "udp 代理暂不支持流量统计"
+            },
+            {
+                "type": "tpl",
+                "visibleOn": "${type != 'udp' && !stats}",
+                "tpl": "代理未开启流量统计，可在编辑代理时开启"
+            }
+        ]
+    }
+};
+
+// 日志查看，定时刷新最近的日志，proxy 为代理名称模板时只看该代理的日志
+const logsDialog = (proxy: string) => ({
+    "title": proxy ? "代理日志" : "运行日志",
+    "size": "xl",
+    "actions": [],
+    "body": {
+        "type": "crud",
+        "api": {
+            "method": "get",
+            "url": "/api/v1/logs",
+            "data": {
+                "level": "${level}",
+                "source": "${source}",
+                "proxy": proxy || "${proxy}",
+                "limit": 500
+            }
+        },
+        "interval": 3000,
+        "silentPolling": true,
+        "loadDataOnce": true,
+        "perPage": 50,
+        "defaultParams": {"level": "info"},
+        "filter": {
+            "title": "",
+            "submitOnChange": true,
+            "body": [
+                {
+                    "type": "select",
+                    "name": "level",
+                    "label": "级别",
+                    "options": [
+                        {label: '调试', value: 'debug'},
+                        {label: '信息', value: 'info'},
+                        {label: '警告', value: 'warn'},
+                        {label: '错误', value: 'error'}
+                    ]
+                },
+                {
+                    "type": "select",
+                    "name": "source",
+                    "label": "来源",
+                    "clearable": true,
+                    "placeholder": "全部",
+                    "options": [
+                        {label: '客户端', value: 'app'},
+                        {label: 'frpc', value: 'frp'}
+                    ]
+                },
+                {
+                    "type": "input-text",
+                    "name": "proxy",
+                    "label": "代理",
+                    "clearable": true,
+                    "visible": !proxy
+                }
+            ]
+        },
+        "orderBy": "time",
+        "orderDir": "desc",
+        "columns": [
+            {"name": "time", "label": "时间", "type": "tpl", "tpl": "${time / 1000000 | date:YYYY-MM-DD HH\\:mm\\:ss:x}"},
+            {"name": "level", "label": "级别", "type": "mapping", "map": {
+                "debug": "<span class='label label-default'>调试</span>",
+                "info": "<span class='label label-info'>信息</span>",
+                "warn": "<span class='label label-warning'>警告</span>",
+                "error": "<span class='label label-danger'>错误</span>"
+            }},
+            {"name": "source", "label": "来源"},
+            {"name": "proxy", "label": "代理"},
+            {"name": "msg", "label": "内容"},
+            {"name": "fields", "label": "字段", "type": "json", "levelExpand": 0}
+        ]
+    }
+});
+
+// 配置变更审计记录，支持按操作、对象、来源筛选并导出
+const auditDialog = {
+    "title": "操作审计",
+    "size": "xl",
+    "actions": [],
+    "body": {
+        "type": "crud",
+        "api": {
+            "method": "get",
+            "url": "/api/v1/audit",
+            "data": {
+                "action": "${action}",
+                "target": "${target}",
+                "source": "${source}",
+                "limit": 1000
+            }
+        },
+        "loadDataOnce": true,
+        "perPage": 50,
+        "filter": {
+            "title": "",
+            "submitOnChange": true,
+            "body": [
+                {
+                    "type": "select",
+                    "name": "action",
+                    "label": "操作",
+                    "clearable": true,
+                    "placeholder": "全部",
+                    "options": [
+                        {label: '新增', value: 'add'},
+                        {label: '编辑', value: 'edit'},
+                        {label: '删除', value: 'delete'},
+                        {label: '启用', value: 'enable'},
+                        {label: '停用', value: 'disable'},
+                        {label: '连接', value: 'connect'},
+                        {label: '修改服务器', value: 'set_server'},
+                        {label: '断开', value: 'unlock'},
+                        {label: '恢复备份', value: 'restore'}
+                    ]
+                },
+                {
+                    "type": "input-text",
+                    "name": "target",
+                    "label": "对象",
+                    "clearable": true
+                },
+                {
+                    "type": "select",
+                    "name": "source",
+                    "label": "来源",
+                    "clearable": true,
+                    "placeholder": "全部",
+                    "options": [
+                        {label: '界面', value: 'gui'},
+                        {label: '接口', value: 'api'},
+                        {label: '系统', value: 'system'}
+                    ]
+                }
+            ]
+        },
+        "headerToolbar": [
+            {
+                "type": "button",
+                "label": "导出 CSV",
+                "actionType": "url",
+                "blank": true,
+                "url": "/api/v1/audit?format=csv&action=${action}&target=${target}&source=${source}"
+            },
+            {
+                "type": "button",
+                "label": "导出 JSONL",
+                "actionType": "url",
+                "blank": true,
+                "url": "/api/v1/audit?format=jsonl&action=${action}&target=${target}&source=${source}"
+            }
+        ],
+        "orderBy": "id",
+        "orderDir": "desc",
+        "columns": [
+            {"name": "time", "label": "时间", "type": "tpl", "tpl": "${time / 1000000 | date:YYYY-MM-DD HH\\:mm\\:ss:x}"},
+            {"name": "action", "label": "操作"},
+            {"name": "target", "label": "对象"},
+            {"name": "source", "label": "来源"},
+            {"name": "client", "label": "客户端"},
+            {"name": "remoteAddr", "label": "地址"},
+            {"name": "before", "label": "变更前", "type": "json", "levelExpand": 0},
+            {"name": "after", "label": "变更后", "type": "json", "levelExpand": 0},
+            {"name": "err", "label": "失败原因"}
+        ]
+    }
+};
+
 addRule(
     // 校验名
     'isIPV4',
@@ -128,10 +717,30 @@ addRule(
 
 class AMISComponent extends React.Component<any, any> {
 
+    amisScoped: any;
+    offEvents: (() => void)[] = [];
+    reloadTimer?: ReturnType<typeof setTimeout>;
+
     constructor(props: any) {
         super(props);
     }
 
+    // 代理或连接状态变化时刷新代理列表，短时间内的多个事件合并为一次刷新
+    componentDidMount() {
+        const reload = () => {
+            clearTimeout(this.reloadTimer);
+            this.reloadTimer = setTimeout(() => {
+                this.amisScoped?.getComponentById('card-service-id')?.reload();
+            }, 300);
+        };
+        this.offEvents = [EventsOn('proxy', reload), EventsOn('connection', reload)];
+    }
+
+    componentWillUnmount() {
+        clearTimeout(this.reloadTimer);
+        this.offEvents.forEach(off => off());
+    }
+
 
     render() {
 
@@ -161,7 +770,10 @@ class AMISComponent extends React.Component<any, any> {
                             "url": "/api/connect",
                             "data": {
                                 serverIp: "${serverIp}",
-                                serverPort: "${serverPort}"
+                                serverPort: "${serverPort}",
+                                dashboardUrl: "${dashboardUrl}",
+                                dashboardUser: "${dashboardUser}",
+                                dashboardPassword: "${dashboardPassword}"
                             },
                         },
                         "id": "server-config-form",
@@ -186,6 +798,13 @@ class AMISComponent extends React.Component<any, any> {
                                 "min": 1,
                                 "max": 65535
                             },
+                            {
+                                "type": "fieldSet",
+                                "title": "frps dashboard",
+                                "collapsable": true,
+                                "collapsed": true,
+                                "body": dashboardFields
+                            },
                             {
                                 "type": "button",
                                 "icon": "fas fa-globe-asia",
@@ -223,7 +842,7 @@ class AMISComponent extends React.Component<any, any> {
                                                 "args": {
                                                     "api": {
                                                         "url": "/api/unlock",
-                                                        "method": "get"
+                                                        "method": "post"
                                                     },
                                                     "data": {
                                                         "serverIp": "${serverIp}",
@@ -356,6 +975,7 @@ class AMISComponent extends React.Component<any, any> {
                                 "api": {
                                     "url": "/api/addProxy",
                                     "method": "post",
+                                    "adaptor": fieldErrorAdaptor,
                                 },
                                 "closeDialogOnSubmit": true,
                                 "reload": "card-service-id",
@@ -369,6 +989,17 @@ class AMISComponent extends React.Component<any, any> {
                                     {
                                         "type": "divider"
                                     },
+                                    {
+                                        "type": "select",
+                                        "name": "type",
+                                        "label": "代理类型",
+                                        "value": "tcp",
+                                        "required": true,
+                                        "options": proxyTypeOptions
+                                    },
+                                    {
+                                        "type": "divider"
+                                    },
                                     {
                                         "type": "input-number",
                                         "name": "localPort",
@@ -385,11 +1016,70 @@ class AMISComponent extends React.Component<any, any> {
                                         "type": "input-number",
                                         "name": "remotePort",
                                         "label": "远程端口",
+                                        "description": "填 0 由服务器分配",
                                         "required": true,
                                         "step": 1,
-                                        "min": 1,
+                                        "min": 0,
                                         "max": 65535
                                     },
+                                    {
+                                        "type": "button",
+                                        "label": "查找空闲端口",
+                                        "level": "link",
+                                        "actionType": "ajax",
+                                        "api": freePortApi
+                                    },
+                                    {
+                                        "type": "divider"
+                                    },
+                                    {
+                                        "type": "input-text",
+                                        "name": "probePath",
+                                        "label": "HTTP 探测路径",
+                                        "placeholder": "/health",
+                                        "description": "为空时只检查本地端口能否连接"
+                                    },
+                                    {
+                                        "type": "input-number",
+                                        "name": "probeStatus",
+                                        "label": "期望状态码",
+                                        "placeholder": "200",
+                                        "min": 100,
+                                        "max": 599,
+                                        "visibleOn": "${probePath}"
+                                    },
+                                    {
+                                        "type": "fieldSet",
+                                        "title": "frp 健康检查",
+                                        "collapsable": true,
+                                        "collapsed": true,
+                                        "visibleOn": "${type != 'udp'}",
+                                        "body": healthCheckFields
+                                    },
+                                    {
+                                        "type": "fieldSet",
+                                        "title": "负载均衡组",
+                                        "collapsable": true,
+                                        "collapsed": true,
+                                        "visibleOn": "${type != 'udp'}",
+                                        "body": groupFields
+                                    },
+                                    proxyProtocolField,
+                                    statsField,
+                                    {
+                                        "type": "fieldSet",
+                                        "title": "定时开关",
+                                        "collapsable": true,
+                                        "collapsed": true,
+                                        "body": scheduleFields
+                                    },
+                                    {
+                                        "type": "fieldSet",
+                                        "title": "临时代理",
+                                        "collapsable": true,
+                                        "collapsed": true,
+                                        "body": expiryFields
+                                    },
                                     {
                                         "type": "divider"
                                     }
@@ -399,6 +1089,48 @@ class AMISComponent extends React.Component<any, any> {
                         },
                         "label": "新增映射",
                     },
+                    {
+                        "type": "button",
+                        "icon": "fas fa-server",
+                        "label": "服务器信息",
+                        "actionType": "dialog",
+                        "dialog": dashboardDialog
+                    },
+                    {
+                        "type": "button",
+                        "icon": "fas fa-file-alt",
+                        "label": "运行日志",
+                        "actionType": "dialog",
+                        "dialog": logsDialog("")
+                    },
+                    {
+                        "type": "button",
+                        "icon": "fas fa-history",
+                        "label": "操作审计",
+                        "actionType": "dialog",
+                        "dialog": auditDialog
+                    },
+                    {
+                        "type": "button",
+                        "icon": "fas fa-sitemap",
+                        "label": "负载均衡组",
+                        "actionType": "dialog",
+                        "dialog": {
+                            "title": "负载均衡组",
+                            "actions": [],
+                            "body": {
+                                "type": "crud",
+                                "api": "/api/v1/groups",
+                                "columns": [
+                                    {"name": "group", "label": "组名"},
+                                    {"name": "type", "label": "类型"},
+                                    {"name": "remotePort", "label": "远程端口"},
+                                    {"name": "members", "label": "本地代理", "type": "each", "items": {"type": "tpl", "tpl": "<span class='label label-info m-r-xs'>${item}</span>"}},
+                                    {"name": "enabled", "label": "已开启"}
+                                ]
+                            }
+                        }
+                    },
                     {
                         "type": "divider"
                     },
@@ -413,8 +1145,6 @@ class AMISComponent extends React.Component<any, any> {
                                 time: "${time}"
                             },
                         },
-                        "interval": 5000,
-                        "silentPolling": true,
                         "body": [
                             {
                                 "mode": "cards",
@@ -468,6 +1198,83 @@ class AMISComponent extends React.Component<any, any> {
                                         "avatarClassName": "pull-left thumb b-3x m-r"
                                     },
                                     "body": [
+                                        {
+                                            "type": "mapping",
+                                            "name": "errReason",
+                                            "label": "启动错误",
+                                            "className": "text-danger",
+                                            "map": proxyErrReasonMap,
+                                            "visibleOn": "${errReason}"
+                                        },
+                                        {
+                                            "name": "group",
+                                            "label": "负载均衡组",
+                                            "visibleOn": "${group}"
+                                        },
+                                        {
+                                            "type": "mapping",
+                                            "name": "runStatus",
+                                            "label": "运行状态",
+                                            "map": proxyRunStatusMap,
+                                            "visibleOn": "${status}"
+                                        },
+                                        {
+                                            "type": "mapping",
+                                            "name": "healthStatus",
+                                            "label": "健康检查",
+                                            "map": healthStatusMap,
+                                            "visibleOn": "${healthStatus}"
+                                        },
+                                        {
+                                            "type": "tpl",
+                                            "label": "定时开关",
+                                            "tpl": "${nextTransition / 1000000 | date:MM-DD HH\\:mm:x} ${nextStatus ? '开启' : '关闭'}",
+                                            "visibleOn": "${nextTransition}"
+                                        },
+                                        {
+                                            "type": "tpl",
+                                            "label": "到期",
+                                            "className": "text-warning",
+                                            "tpl": "${expiresInS | duration} 后${expireAction == 'delete' ? '删除' : '关闭'}",
+                                            "visibleOn": "${expireAt}"
+                                        },
+                                        {
+                                            "name": "err",
+                                            "label": "错误详情",
+                                            "className": "text-danger",
+                                            "visibleOn": "${err}"
+                                        },
+                                        {
+                                            "type": "mapping",
+                                            "name": "localStatus",
+                                            "label": "本地服务",
+                                            "map": localStatusMap,
+                                            "visibleOn": "${localStatus}"
+                                        },
+                                        {
+                                            "name": "localErr",
+                                            "label": "探测结果",
+                                            "className": "text-danger",
+                                            "visibleOn": "${localStatus == 'down'}"
+                                        },
+                                        {
+                                            "type": "mapping",
+                                            "name": "serverStatus",
+                                            "label": "frps 状态",
+                                            "map": serverStatusMap,
+                                            "visibleOn": "${serverStatus}"
+                                        },
+                                        {
+                                            "type": "tpl",
+                                            "label": "今日流量",
+                                            "tpl": "流入 ${todayTrafficIn | bytes}，流出 ${todayTrafficOut | bytes}，连接 ${serverConns}",
+                                            "visibleOn": "${serverStatus}"
+                                        },
+                                        {
+                                            "name": "lastStartTime",
+                                            "label": "最近启动",
+                                            "visibleOn": "${lastStartTime}"
+                                        },
                                         {
                                             "label": "本地端口",
                                             "name": "localPort"
@@ -511,14 +1318,29 @@ class AMISComponent extends React.Component<any, any> {
                                                     "api": {
                                                         "url": "/api/editProxy",
                                                         "method": "post",
+                                                        "adaptor": fieldErrorAdaptor,
                                                     },
                                                     "body": [
+                                                        {
+                                                            "type": "hidden",
+                                                            "name": "originName",
+                                                            "value": "${proxyName}"
+                                                        },
                                                         {
                                                             "type": "input-text",
                                                             "name": "proxyName",
                                                             "label": "代理名称",
+                                                            "required": true
+                                                        },
+                                                        {
+                                                            "type": "divider"
+                                                        },
+                                                        {
+                                                            "type": "select",
+                                                            "name": "type",
+                                                            "label": "代理类型",
                                                             "required": true,
-                                                            "readOnly": true,
+                                                            "options": proxyTypeOptions
                                                         },
                                                         {
                                                             "type": "divider"
@@ -539,11 +1361,70 @@ class AMISComponent extends React.Component<any, any> {
                                                             "type": "input-number",
                                                             "name": "remotePort",
                                                             "label": "远程端口",
+                                                            "description": "填 0 由服务器分配",
                                                             "required": true,
                                                             "step": 1,
-                                                            "min": 1,
+                                                            "min": 0,
                                                             "max": 65535
                                                         },
+                                                        {
+                                                            "type": "button",
+                                                            "label": "查找空闲端口",
+                                                            "level": "link",
+                                                            "actionType": "ajax",
+                                                            "api": freePortApi
+                                                        },
+                                                        {
+                                                            "type": "divider"
+                                                        },
+                                                        {
+                                                            "type": "input-text",
+                                                            "name": "probePath",
+                                                            "label": "HTTP 探测路径",
+                                                            "placeholder": "/health",
+                                                            "description": "为空时只检查本地端口能否连接"
+                                                        },
+                                                        {
+                                                            "type": "input-number",
+                                                            "name": "probeStatus",
+                                                            "label": "期望状态码",
+                                                            "placeholder": "200",
+                                                            "min": 100,
+                                                            "max": 599,
+                                                            "visibleOn": "${probePath}"
+                                                        },
+                                                        {
+                                                            "type": "fieldSet",
+                                                            "title": "frp 健康检查",
+                                                            "collapsable": true,
+                                                            "collapsed": true,
+                                                            "visibleOn": "${type != 'udp'}",
+                                                            "body": healthCheckFields
+                                                        },
+                                                        {
+                                                            "type": "fieldSet",
+                                                            "title": "负载均衡组",
+                                                            "collapsable": true,
+                                                            "collapsed": true,
+                                                            "visibleOn": "${type != 'udp'}",
+                                                            "body": groupFields
+                                                        },
+                                                        proxyProtocolField,
+                                                        statsField,
+                                                        {
+                                                            "type": "fieldSet",
+                                                            "title": "定时开关",
+                                                            "collapsable": true,
+                                                            "collapsed": true,
+                                                            "body": scheduleFields
+                                                        },
+                                                        {
+                                                            "type": "fieldSet",
+                                                            "title": "临时代理",
+                                                            "collapsable": true,
+                                                            "collapsed": true,
+                                                            "body": expiryFields
+                                                        },
                                                         {
                                                             "type": "divider"
                                                         }
@@ -560,6 +1441,62 @@ class AMISComponent extends React.Component<any, any> {
                                             },
                                             "label": "编辑"
                                         },
+                                        {
+                                            "type": "button",
+                                            "icon": "fa fa-vial",
+                                            "actionType": "dialog",
+                                            "visibleOn": "${proxyProtocolVersion}",
+                                            "dialog": protocolTestDialog,
+                                            "label": "协议测试"
+                                        },
+                                        {
+                                            "type": "button",
+                                            "icon": "fa fa-chart-line",
+                                            "actionType": "dialog",
+                                            "dialog": statsDialog,
+                                            "label": "流量"
+                                        },
+                                        {
+                                            "type": "button",
+                                            "icon": "fa fa-file-alt",
+                                            "actionType": "dialog",
+                                            "dialog": logsDialog("${proxyName}"),
+                                            "label": "日志"
+                                        },
+                                        {
+                                            "type": "button",
+                                            "icon": "fa fa-hourglass-half",
+                                            "hiddenOn": "${schedule.cron || schedule.windows}",
+                                            "actionType": "dialog",
+                                            "dialog": enableForDialog,
+                                            "label": "临时开启"
+                                        },
+                                        {
+                                            "type": "button",
+                                            "icon": "fa fa-hourglass",
+                                            "visibleOn": "${expireAt}",
+                                            "label": "取消到期",
+                                            "onEvent": {
+                                                "click": {
+                                                    "actions": [
+                                                        {
+                                                            "actionType": "ajax",
+                                                            "args": {
+                                                                "api": {
+                                                                    "url": "/api/v1/proxies/${proxyName}",
+                                                                    "method": "patch",
+                                                                    "data": {"expireAt": 0}
+                                                                }
+                                                            }
+                                                        },
+                                                        {
+                                                            "actionType": "reload",
+                                                            "componentId": "card-service-id"
+                                                        }
+                                                    ]
+                                                }
+                                            }
+                                        },
                                         {
                                             "type": "button",
                                             "icon": "fa fa-trash",
@@ -606,7 +1543,8 @@ class AMISComponent extends React.Component<any, any> {
             },
             {
                 // props...
-                onBroadcast: handleBroadcast
+                onBroadcast: handleBroadcast,
+                scopeRef: (ref: any) => (this.amisScoped = ref)
             }
             ,
             env
@@ -617,6 +1555,21 @@ class AMISComponent extends React.Component<any, any> {
 
 class APP extends React.Component<any, any> {
 
+    offExpiring?: () => void;
+
+    // 代理即将到期时提醒
+    componentDidMount() {
+        this.offExpiring = EventsOn('proxy_expiring', (e: any) => {
+            const body = e.body;
+            const action = body.expireAction === 'delete' ? '删除' : '关闭';
+            toast.warning(`代理 ${body.proxyName} 将在 ${Math.ceil(body.expiresInS / 60)} 分钟后${action}`, '代理即将到期');
+        });
+    }
+
+    componentWillUnmount() {
+        this.offExpiring && this.offExpiring();
+    }
+
     render() {
 
         return (
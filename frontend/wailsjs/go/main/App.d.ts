@@ -1,6 +1,43 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
+import {message} from '../models';
+
+export function AddProxy(arg1:message.ProxyMsg):Promise<message.ProxyMsgVo>;
+
+export function Connect(arg1:message.ConnectServerMsg):Promise<message.ServiceInfo>;
+
+export function DeleteProxy(arg1:string):Promise<void>;
+
+export function Disconnect():Promise<message.ServiceInfo>;
+
+export function EnableProxyWithExpiry(arg1:string,arg2:message.ProxyExpiry):Promise<message.ProxyMsgVo>;
+
+export function FindRemotePort(arg1:string):Promise<message.FreePort>;
+
+export function GetAuditRecords(arg1:string,arg2:string,arg3:string,arg4:number):Promise<Array<message.AuditRecord>>;
+
+export function GetDashboardInfo():Promise<message.DashboardInfo>;
+
+export function GetLogs(arg1:string,arg2:string,arg3:number):Promise<Array<message.LogEntry>>;
+
+export function GetProtocolTest(arg1:string):Promise<message.ProtocolTest>;
+
+export function GetProxyStats(arg1:string,arg2:string):Promise<message.ProxyStats>;
+
+export function GetServerInfo():Promise<message.ServiceInfo>;
 
 export function Greet(arg1:string):Promise<string>;
 
+export function ListGroups():Promise<Array<message.ProxyGroup>>;
+
+export function ListProxies():Promise<Array<message.ProxyMsgVo>>;
+
 export function SetFrpServiceConfig(arg1:string,arg2:number):Promise<string>;
+
+export function SetProxyEnabled(arg1:string,arg2:boolean):Promise<message.ProxyMsgVo>;
+
+export function StartProtocolTest(arg1:string):Promise<message.ProtocolTest>;
+
+export function StopProtocolTest(arg1:string):Promise<void>;
+
+export function UpdateProxy(arg1:string,arg2:message.ProxyPatch):Promise<message.ProxyMsgVo>;
@@ -2,10 +2,82 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function AddProxy(arg1) {
+  return window['go']['main']['App']['AddProxy'](arg1);
+}
+
+export function Connect(arg1) {
+  return window['go']['main']['App']['Connect'](arg1);
+}
+
+export function DeleteProxy(arg1) {
+  return window['go']['main']['App']['DeleteProxy'](arg1);
+}
+
+export function Disconnect() {
+  return window['go']['main']['App']['Disconnect']();
+}
+
+export function EnableProxyWithExpiry(arg1, arg2) {
+  return window['go']['main']['App']['EnableProxyWithExpiry'](arg1, arg2);
+}
+
+export function FindRemotePort(arg1) {
+  return window['go']['main']['App']['FindRemotePort'](arg1);
+}
+
+export function GetAuditRecords(arg1, arg2, arg3, arg4) {
+  return window['go']['main']['App']['GetAuditRecords'](arg1, arg2, arg3, arg4);
+}
+
+export function GetDashboardInfo() {
+  return window['go']['main']['App']['GetDashboardInfo']();
+}
+
+export function GetLogs(arg1, arg2, arg3) {
+  return window['go']['main']['App']['GetLogs'](arg1, arg2, arg3);
+}
+
+export function GetProtocolTest(arg1) {
+  return window['go']['main']['App']['GetProtocolTest'](arg1);
+}
+
+export function GetProxyStats(arg1, arg2) {
+  return window['go']['main']['App']['GetProxyStats'](arg1, arg2);
+}
+
+export function GetServerInfo() {
+  return window['go']['main']['App']['GetServerInfo']();
+}
+
 export function Greet(arg1) {
   return window['go']['main']['App']['Greet'](arg1);
 }
 
+export function ListGroups() {
+  return window['go']['main']['App']['ListGroups']();
+}
+
+export function ListProxies() {
+  return window['go']['main']['App']['ListProxies']();
+}
+
 export function SetFrpServiceConfig(arg1, arg2) {
   return window['go']['main']['App']['SetFrpServiceConfig'](arg1, arg2);
 }
+
+export function SetProxyEnabled(arg1, arg2) {
+  return window['go']['main']['App']['SetProxyEnabled'](arg1, arg2);
+}
+
+export function StartProtocolTest(arg1) {
+  return window['go']['main']['App']['StartProtocolTest'](arg1);
+}
+
+export function StopProtocolTest(arg1) {
+  return window['go']['main']['App']['StopProtocolTest'](arg1);
+}
+
+export function UpdateProxy(arg1, arg2) {
+  return window['go']['main']['App']['UpdateProxy'](arg1, arg2);
+}
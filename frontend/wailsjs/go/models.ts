@@ -0,0 +1,560 @@
+export namespace message {
+	
+	export class AuditRecord {
+	    id: string;
+	    time: number;
+	    action: string;
+	    target: string;
+	    source: string;
+	    client: string;
+	    remoteAddr: string;
+	    before: any;
+	    after: any;
+	    err?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new AuditRecord(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.time = source["time"];
+	        this.action = source["action"];
+	        this.target = source["target"];
+	        this.source = source["source"];
+	        this.client = source["client"];
+	        this.remoteAddr = source["remoteAddr"];
+	        this.before = source["before"];
+	        this.after = source["after"];
+	        this.err = source["err"];
+	    }
+	}
+	export class ConnectServerMsg {
+	    serverIp: string;
+	    serverPort: number;
+	    dashboardUrl: string;
+	    dashboardUser: string;
+	    dashboardPassword: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ConnectServerMsg(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.serverIp = source["serverIp"];
+	        this.serverPort = source["serverPort"];
+	        this.dashboardUrl = source["dashboardUrl"];
+	        this.dashboardUser = source["dashboardUser"];
+	        this.dashboardPassword = source["dashboardPassword"];
+	    }
+	}
+	export class DashboardInfo {
+	    version: string;
+	    clientCounts: number;
+	    curConns: number;
+	    totalTrafficIn: number;
+	    totalTrafficOut: number;
+	    proxyTypeCounts: {[key: string]: number};
+	
+	    static createFrom(source: any = {}) {
+	        return new DashboardInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.version = source["version"];
+	        this.clientCounts = source["clientCounts"];
+	        this.curConns = source["curConns"];
+	        this.totalTrafficIn = source["totalTrafficIn"];
+	        this.totalTrafficOut = source["totalTrafficOut"];
+	        this.proxyTypeCounts = source["proxyTypeCounts"];
+	    }
+	}
+	export class FreePort {
+	    type: string;
+	    port: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new FreePort(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.type = source["type"];
+	        this.port = source["port"];
+	    }
+	}
+	export class HealthCheckConf {
+	    type: string;
+	    timeoutS: number;
+	    maxFailed: number;
+	    intervalS: number;
+	    url: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new HealthCheckConf(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.type = source["type"];
+	        this.timeoutS = source["timeoutS"];
+	        this.maxFailed = source["maxFailed"];
+	        this.intervalS = source["intervalS"];
+	        this.url = source["url"];
+	    }
+	}
+	export class LogEntry {
+	    time: number;
+	    level: string;
+	    source: string;
+	    proxy?: string;
+	    msg: string;
+	    fields?: {[key: string]: string};
+	
+	    static createFrom(source: any = {}) {
+	        return new LogEntry(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.time = source["time"];
+	        this.level = source["level"];
+	        this.source = source["source"];
+	        this.proxy = source["proxy"];
+	        this.msg = source["msg"];
+	        this.fields = source["fields"];
+	    }
+	}
+	export class ProtocolRecord {
+	    time: number;
+	    version: number;
+	    sourceAddr: string;
+	    destAddr: string;
+	    peerAddr: string;
+	    err: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ProtocolRecord(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.time = source["time"];
+	        this.version = source["version"];
+	        this.sourceAddr = source["sourceAddr"];
+	        this.destAddr = source["destAddr"];
+	        this.peerAddr = source["peerAddr"];
+	        this.err = source["err"];
+	    }
+	}
+	export class ProtocolTest {
+	    proxyName: string;
+	    localPort: number;
+	    running: boolean;
+	    expiresAt: number;
+	    rows: ProtocolRecord[];
+	
+	    static createFrom(source: any = {}) {
+	        return new ProtocolTest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.proxyName = source["proxyName"];
+	        this.localPort = source["localPort"];
+	        this.running = source["running"];
+	        this.expiresAt = source["expiresAt"];
+	        this.rows = this.convertValues(source["rows"], ProtocolRecord);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ProxyExpiry {
+	    expireIn: string;
+	    expireAt: number;
+	    expireAction: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ProxyExpiry(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.expireIn = source["expireIn"];
+	        this.expireAt = source["expireAt"];
+	        this.expireAction = source["expireAction"];
+	    }
+	}
+	export class ProxyGroup {
+	    group: string;
+	    type: string;
+	    remotePort: number;
+	    members: string[];
+	    enabled: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new ProxyGroup(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.group = source["group"];
+	        this.type = source["type"];
+	        this.remotePort = source["remotePort"];
+	        this.members = source["members"];
+	        this.enabled = source["enabled"];
+	    }
+	}
+	export class ScheduleConf {
+	    cron: string;
+	    windows: string;
+	    timezone: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ScheduleConf(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.cron = source["cron"];
+	        this.windows = source["windows"];
+	        this.timezone = source["timezone"];
+	    }
+	}
+	export class ProxyMsg {
+	    proxyName: string;
+	    remoteProxyName: string;
+	    localPort: number;
+	    remotePort: number;
+	    type: string;
+	    status: boolean;
+	    runStatus: string;
+	    addTime: number;
+	    remote_addr: string;
+	    err: string;
+	    errReason: string;
+	    probePath: string;
+	    probeStatus: number;
+	    localStatus: string;
+	    localErr: string;
+	    healthCheck: HealthCheckConf;
+	    healthStatus: string;
+	    healthCheckAt: number;
+	    group: string;
+	    groupKey: string;
+	    proxyProtocolVersion: string;
+	    stats: boolean;
+	    schedule: ScheduleConf;
+	    expireAt: number;
+	    expireAction: string;
+	    expireIn?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ProxyMsg(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.proxyName = source["proxyName"];
+	        this.remoteProxyName = source["remoteProxyName"];
+	        this.localPort = source["localPort"];
+	        this.remotePort = source["remotePort"];
+	        this.type = source["type"];
+	        this.status = source["status"];
+	        this.runStatus = source["runStatus"];
+	        this.addTime = source["addTime"];
+	        this.remote_addr = source["remote_addr"];
+	        this.err = source["err"];
+	        this.errReason = source["errReason"];
+	        this.probePath = source["probePath"];
+	        this.probeStatus = source["probeStatus"];
+	        this.localStatus = source["localStatus"];
+	        this.localErr = source["localErr"];
+	        this.healthCheck = this.convertValues(source["healthCheck"], HealthCheckConf);
+	        this.healthStatus = source["healthStatus"];
+	        this.healthCheckAt = source["healthCheckAt"];
+	        this.group = source["group"];
+	        this.groupKey = source["groupKey"];
+	        this.proxyProtocolVersion = source["proxyProtocolVersion"];
+	        this.stats = source["stats"];
+	        this.schedule = this.convertValues(source["schedule"], ScheduleConf);
+	        this.expireAt = source["expireAt"];
+	        this.expireAction = source["expireAction"];
+	        this.expireIn = source["expireIn"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ProxyMsgVo {
+	    proxyName: string;
+	    type: string;
+	    localPort: number;
+	    remotePort: number;
+	    status: boolean;
+	    remoteAddr: string;
+	    addTime: number;
+	    err: string;
+	    errReason: string;
+	    runStatus: string;
+	    probePath: string;
+	    probeStatus: number;
+	    localStatus: string;
+	    localErr: string;
+	    healthCheck: HealthCheckConf;
+	    healthStatus: string;
+	    healthCheckAt: number;
+	    group: string;
+	    hasGroupKey: boolean;
+	    proxyProtocolVersion: string;
+	    stats: boolean;
+	    schedule: ScheduleConf;
+	    nextTransition: number;
+	    nextStatus: boolean;
+	    expireAt: number;
+	    expireAction: string;
+	    expiresInS: number;
+	    serverStatus: string;
+	    todayTrafficIn: number;
+	    todayTrafficOut: number;
+	    serverConns: number;
+	    lastStartTime: string;
+	    lastCloseTime: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ProxyMsgVo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.proxyName = source["proxyName"];
+	        this.type = source["type"];
+	        this.localPort = source["localPort"];
+	        this.remotePort = source["remotePort"];
+	        this.status = source["status"];
+	        this.remoteAddr = source["remoteAddr"];
+	        this.addTime = source["addTime"];
+	        this.err = source["err"];
+	        this.errReason = source["errReason"];
+	        this.runStatus = source["runStatus"];
+	        this.probePath = source["probePath"];
+	        this.probeStatus = source["probeStatus"];
+	        this.localStatus = source["localStatus"];
+	        this.localErr = source["localErr"];
+	        this.healthCheck = this.convertValues(source["healthCheck"], HealthCheckConf);
+	        this.healthStatus = source["healthStatus"];
+	        this.healthCheckAt = source["healthCheckAt"];
+	        this.group = source["group"];
+	        this.hasGroupKey = source["hasGroupKey"];
+	        this.proxyProtocolVersion = source["proxyProtocolVersion"];
+	        this.stats = source["stats"];
+	        this.schedule = this.convertValues(source["schedule"], ScheduleConf);
+	        this.nextTransition = source["nextTransition"];
+	        this.nextStatus = source["nextStatus"];
+	        this.expireAt = source["expireAt"];
+	        this.expireAction = source["expireAction"];
+	        this.expiresInS = source["expiresInS"];
+	        this.serverStatus = source["serverStatus"];
+	        this.todayTrafficIn = source["todayTrafficIn"];
+	        this.todayTrafficOut = source["todayTrafficOut"];
+	        this.serverConns = source["serverConns"];
+	        this.lastStartTime = source["lastStartTime"];
+	        this.lastCloseTime = source["lastCloseTime"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ProxyPatch {
+	    proxyName?: string;
+	    type?: string;
+	    localPort?: number;
+	    remotePort?: number;
+	    probePath?: string;
+	    probeStatus?: number;
+	    healthCheck?: HealthCheckConf;
+	    group?: string;
+	    groupKey?: string;
+	    proxyProtocolVersion?: string;
+	    stats?: boolean;
+	    schedule?: ScheduleConf;
+	    expireAt?: number;
+	    expireIn?: string;
+	    expireAction?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ProxyPatch(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.proxyName = source["proxyName"];
+	        this.type = source["type"];
+	        this.localPort = source["localPort"];
+	        this.remotePort = source["remotePort"];
+	        this.probePath = source["probePath"];
+	        this.probeStatus = source["probeStatus"];
+	        this.healthCheck = this.convertValues(source["healthCheck"], HealthCheckConf);
+	        this.group = source["group"];
+	        this.groupKey = source["groupKey"];
+	        this.proxyProtocolVersion = source["proxyProtocolVersion"];
+	        this.stats = source["stats"];
+	        this.schedule = this.convertValues(source["schedule"], ScheduleConf);
+	        this.expireAt = source["expireAt"];
+	        this.expireIn = source["expireIn"];
+	        this.expireAction = source["expireAction"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class StatsPoint {
+	    time: number;
+	    bytesIn: number;
+	    bytesOut: number;
+	    newConns: number;
+	    curConns: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new StatsPoint(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.time = source["time"];
+	        this.bytesIn = source["bytesIn"];
+	        this.bytesOut = source["bytesOut"];
+	        this.newConns = source["newConns"];
+	        this.curConns = source["curConns"];
+	    }
+	}
+	export class ProxyStats {
+	    proxyName: string;
+	    bytesIn: number;
+	    bytesOut: number;
+	    totalConns: number;
+	    curConns: number;
+	    rows: StatsPoint[];
+	
+	    static createFrom(source: any = {}) {
+	        return new ProxyStats(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.proxyName = source["proxyName"];
+	        this.bytesIn = source["bytesIn"];
+	        this.bytesOut = source["bytesOut"];
+	        this.totalConns = source["totalConns"];
+	        this.curConns = source["curConns"];
+	        this.rows = this.convertValues(source["rows"], StatsPoint);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class ServiceInfo {
+	    serverIp: string;
+	    serverPort: number;
+	    runStatus: number;
+	    time: number;
+	    dashboardUrl: string;
+	    dashboardUser: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ServiceInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.serverIp = source["serverIp"];
+	        this.serverPort = source["serverPort"];
+	        this.runStatus = source["runStatus"];
+	        this.time = source["time"];
+	        this.dashboardUrl = source["dashboardUrl"];
+	        this.dashboardUser = source["dashboardUser"];
+	    }
+	}
+
+}
+
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"net"
+	"strconv"
+
+	"github.com/douguohai/frp-client/logger"
+	"github.com/douguohai/frp-client/utils"
+	"github.com/fatedier/frp/pkg/config"
+)
+
+var (
+	frpcAdminPortFlag = flag.Int("frpc-admin-port", 0, "frpc admin 接口的固定端口，仅监听 127.0.0.1，0 为随机端口")
+
+	// frpc admin 接口凭据，每次启动随机生成，只保存在内存中
+	frpcAdminUser, frpcAdminPwd string
+)
+
+func init() {
+	frpcAdminUser = "frpc-" + utils.RandomHex(4)
+	frpcAdminPwd = utils.RandomHex(16)
+}
+
+// applyFrpcAdminConf 设置 frpc admin 接口，代理状态通过该接口读取
+// 只监听本机回环地址，并使用随机凭据；frp 断开后不释放 admin 端口，固定端口被占用时改用随机端口
+func applyFrpcAdminConf(cfg *config.ClientCommonConf) {
+	cfg.AdminAddr = "127.0.0.1"
+	cfg.AdminUser = frpcAdminUser
+	cfg.AdminPwd = frpcAdminPwd
+	cfg.AdminPort = *frpcAdminPortFlag
+	if cfg.AdminPort == 0 {
+		return
+	}
+	ln, err := net.Listen("tcp", net.JoinHostPort(cfg.AdminAddr, strconv.Itoa(cfg.AdminPort)))
+	if err != nil {
+		logger.Warn("frpc admin 端口已被占用，改用随机端口", "port", cfg.AdminPort, "err", err)
+		cfg.AdminPort = 0
+		return
+	}
+	ln.Close()
+	logger.Info("frpc admin 接口监听", "addr", cfg.AdminAddr, "port", cfg.AdminPort)
+}
@@ -0,0 +1,411 @@
+// Package logger 程序和内置 frpc 的日志，按级别输出到控制台和数据目录下的滚动日志文件，
+// 并在内存中保留最近的日志供查询和实时推送
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/douguohai/frp-client/message"
+)
+
+// Level 日志级别
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = [...]string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelError {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel 解析级别名称，为空时返回 info
+func ParseLevel(s string) (Level, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return LevelInfo, nil
+	}
+	if s == "warning" {
+		return LevelWarn, nil
+	}
+	for i, name := range levelNames {
+		if name == s {
+			return Level(i), nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("不支持的日志级别 %q，可选 debug、info、warn、error", s)
+}
+
+const (
+	// FileName 日志文件名，滚动后的文件追加 .1 .2 等序号，序号越大越旧
+	FileName = "frp-client.log"
+	// maxFileSize 日志文件超过该大小时滚动
+	maxFileSize = 10 << 20
+	// maxBackups 保留的滚动文件数
+	maxBackups = 5
+	// recentSize 内存中保留的最近日志条数，查询只在其中查找
+	recentSize = 2000
+	// subscriberBuffer 实时推送的缓冲条数，订阅者处理不过来时丢弃
+	subscriberBuffer = 256
+)
+
+type logger struct {
+	mu      sync.Mutex
+	level   Level
+	console io.Writer
+
+	dir  string
+	file *os.File
+	size int64
+	// loaded 已从日志文件加载历史记录，重新打开目录时不再加载
+	loaded bool
+	// pending 未打开日志文件时的记录，打开后补写
+	pending []message.LogEntry
+
+	recent []message.LogEntry
+	subs   map[chan message.LogEntry]struct{}
+}
+
+var std = &logger{
+	level:   LevelInfo,
+	console: os.Stderr,
+	subs:    map[chan message.LogEntry]struct{}{},
+}
+
+// SetLevel 设置记录的最低级别，低于该级别的日志直接丢弃
+func SetLevel(level Level) {
+	std.mu.Lock()
+	std.level = level
+	std.mu.Unlock()
+}
+
+// GetLevel 当前记录的最低级别
+func GetLevel() Level {
+	std.mu.Lock()
+	defer std.mu.Unlock()
+	return std.level
+}
+
+// SetDir 设置日志文件目录并打开日志文件，dir 为空时关闭日志文件，只输出到控制台和内存
+// 第一次打开时加载文件中最近的日志，并补写打开之前的日志
+func SetDir(dir string) error {
+	std.mu.Lock()
+	defer std.mu.Unlock()
+	if std.file != nil {
+		std.file.Close()
+		std.file = nil
+	}
+	std.dir = ""
+	if dir == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if !std.loaded {
+		std.loaded = true
+		history := readEntries(filepath.Join(dir, FileName), recentSize)
+		std.recent = append(history, std.recent...)
+		std.trimRecent()
+	}
+	std.dir = dir
+	if err := std.open(); err != nil {
+		std.dir = ""
+		return err
+	}
+	pending := std.pending
+	std.pending = nil
+	for _, e := range pending {
+		std.writeFile(e)
+	}
+	return nil
+}
+
+// Dir 日志文件目录，未打开日志文件时为空
+func Dir() string {
+	std.mu.Lock()
+	defer std.mu.Unlock()
+	return std.dir
+}
+
+// Debug 记录调试日志，kv 为成对的字段名和值，字段名 proxy 记为日志的代理名称
+func Debug(msg string, kv ...interface{}) {
+	Log(LevelDebug, message.LogSourceApp, msg, kv...)
+}
+
+// Info 记录一般日志
+func Info(msg string, kv ...interface{}) {
+	Log(LevelInfo, message.LogSourceApp, msg, kv...)
+}
+
+// Warn 记录警告日志
+func Warn(msg string, kv ...interface{}) {
+	Log(LevelWarn, message.LogSourceApp, msg, kv...)
+}
+
+// Error 记录错误日志
+func Error(msg string, kv ...interface{}) {
+	Log(LevelError, message.LogSourceApp, msg, kv...)
+}
+
+// Enabled 指定级别的日志是否会被记录
+func Enabled(level Level) bool {
+	return level >= GetLevel()
+}
+
+// Log 按来源记录日志
+func Log(level Level, source, msg string, kv ...interface{}) {
+	if !Enabled(level) {
+		return
+	}
+	e := message.LogEntry{
+		Time:   time.Now().UnixNano(),
+		Level:  level.String(),
+		Source: source,
+		Msg:    msg,
+	}
+	for i := 0; i < len(kv); i += 2 {
+		key := fmt.Sprint(kv[i])
+		value := "<缺少值>"
+		if i+1 < len(kv) {
+			value = fieldValue(kv[i+1])
+		}
+		if key == "proxy" {
+			e.Proxy = value
+			continue
+		}
+		if e.Fields == nil {
+			e.Fields = map[string]string{}
+		}
+		e.Fields[key] = value
+	}
+	std.write(e)
+}
+
+func fieldValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case error:
+		return v.Error()
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Writer 以指定级别记录写入的每一行，用于接管标准库 log 的输出
+func Writer(level Level) io.Writer {
+	return lineWriter(level)
+}
+
+type lineWriter Level
+
+func (w lineWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		if line != "" {
+			Log(Level(w), message.LogSourceApp, line)
+		}
+	}
+	return len(p), nil
+}
+
+func (l *logger) write(e message.LogEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	fmt.Fprintln(l.console, formatEntry(e))
+	if l.file != nil {
+		l.writeFile(e)
+	} else if l.dir == "" {
+		l.pending = append(l.pending, e)
+		if len(l.pending) > recentSize {
+			l.pending = l.pending[len(l.pending)-recentSize:]
+		}
+	}
+
+	l.recent = append(l.recent, e)
+	l.trimRecent()
+
+	for ch := range l.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// formatEntry 控制台输出的格式
+func formatEntry(e message.LogEntry) string {
+	var b strings.Builder
+	b.WriteString(time.Unix(0, e.Time).Format("2006-01-02 15:04:05.000"))
+	b.WriteString(" [" + strings.ToUpper(e.Level[:1]) + "] [" + e.Source + "]")
+	if e.Proxy != "" {
+		b.WriteString(" [" + e.Proxy + "]")
+	}
+	b.WriteString(" " + e.Msg)
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%q", k, e.Fields[k])
+	}
+	return b.String()
+}
+
+// writeFile 调用方需持有 mu，写入失败时只输出到控制台，避免递归记录
+func (l *logger) writeFile(e message.LogEntry) {
+	line, _ := json.Marshal(e)
+	line = append(line, '\n')
+	if l.size+int64(len(line)) > maxFileSize && l.size > 0 {
+		if err := l.rotate(); err != nil {
+			fmt.Fprintln(l.console, "日志文件滚动失败:", err)
+			return
+		}
+	}
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		fmt.Fprintln(l.console, "写入日志文件失败:", err)
+	}
+}
+
+func (l *logger) open() error {
+	f, err := os.OpenFile(filepath.Join(l.dir, FileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.file = f
+	l.size = info.Size()
+	return nil
+}
+
+// rotate 关闭当前文件，依次将 .N 改为 .N+1，超出保留数量的删除
+func (l *logger) rotate() error {
+	l.file.Close()
+	l.file = nil
+	base := filepath.Join(l.dir, FileName)
+	os.Remove(fmt.Sprintf("%s.%d", base, maxBackups))
+	for i := maxBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", base, i), fmt.Sprintf("%s.%d", base, i+1))
+	}
+	if err := os.Rename(base, base+".1"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return l.open()
+}
+
+func (l *logger) trimRecent() {
+	if len(l.recent) > recentSize {
+		l.recent = append([]message.LogEntry(nil), l.recent[len(l.recent)-recentSize:]...)
+	}
+}
+
+// readEntries 读取日志文件最后 n 条记录，无法解析的行忽略
+func readEntries(path string, n int) []message.LogEntry {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	entries := []message.LogEntry{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), maxFileSize)
+	for scanner.Scan() {
+		e := message.LogEntry{}
+		if json.Unmarshal(scanner.Bytes(), &e) != nil || e.Time == 0 {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) > n {
+		entries = entries[len(entries)-n:]
+	}
+	return entries
+}
+
+// Filter 日志查询条件，零值表示不限制
+type Filter struct {
+	Level  Level  // 最低级别
+	Source string // 来源
+	Proxy  string // 代理名称
+	Since  int64  // 只返回该时间之后的日志
+	Limit  int    // 最多返回最近的条数
+}
+
+// Match 日志是否满足查询条件，不检查 Limit
+func (f Filter) Match(e message.LogEntry) bool {
+	level, err := ParseLevel(e.Level)
+	if err != nil || level < f.Level {
+		return false
+	}
+	if f.Source != "" && e.Source != f.Source {
+		return false
+	}
+	if f.Proxy != "" && e.Proxy != f.Proxy {
+		return false
+	}
+	return e.Time > f.Since
+}
+
+// Query 查询内存中最近的日志，按时间排序
+func Query(f Filter) []message.LogEntry {
+	std.mu.Lock()
+	defer std.mu.Unlock()
+
+	entries := []message.LogEntry{}
+	for i := len(std.recent) - 1; i >= 0; i-- {
+		if f.Limit > 0 && len(entries) >= f.Limit {
+			break
+		}
+		if f.Match(std.recent[i]) {
+			entries = append(entries, std.recent[i])
+		}
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries
+}
+
+// Subscribe 订阅新记录的日志，用于实时推送
+func Subscribe() chan message.LogEntry {
+	ch := make(chan message.LogEntry, subscriberBuffer)
+	std.mu.Lock()
+	std.subs[ch] = struct{}{}
+	std.mu.Unlock()
+	return ch
+}
+
+// Unsubscribe 取消订阅
+func Unsubscribe(ch chan message.LogEntry) {
+	std.mu.Lock()
+	delete(std.subs, ch)
+	std.mu.Unlock()
+}
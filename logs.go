@@ -0,0 +1,161 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/douguohai/frp-client/logger"
+	"github.com/douguohai/frp-client/message"
+	"github.com/douguohai/frp-client/service"
+)
+
+const (
+	// logDirName 日志文件在数据目录下的子目录，不随备份迁移
+	logDirName = "logs"
+	// defaultLogLimit 查询日志默认返回的条数
+	defaultLogLimit = 200
+)
+
+var logLevelFlag = flag.String("log-level", "info", "日志级别 debug、info、warn、error，同时作用于内置的 frpc")
+
+// initLogging 设置日志级别，接管标准库 log 和 frpc 的日志，在打开数据目录前调用
+func initLogging() {
+	level, err := logger.ParseLevel(*logLevelFlag)
+	logger.SetLevel(level)
+	if err != nil {
+		logger.Warn("日志级别格式错误，使用 info", "err", err)
+	}
+
+	log.SetFlags(0)
+	log.SetOutput(logger.Writer(logger.LevelInfo))
+	if err := service.CaptureFrpLog(level); err != nil {
+		logger.Error("接管 frpc 日志失败", "err", err)
+	}
+}
+
+// logDir 日志文件目录
+func logDir() string {
+	return filepath.Join(storeDir, logDirName)
+}
+
+// logsHandler GET /api/logs?level=warn&proxy=web&source=frp&since=&limit=200
+func logsHandler(writer http.ResponseWriter, request *http.Request) {
+	filter, err := parseLogFilter(request.URL.Query(), defaultLogLimit)
+	if err != nil {
+		writeAPIError(writer, err)
+		return
+	}
+	writeAPIData(writer, http.StatusOK, message.LogEntries{Items: logger.Query(filter)})
+}
+
+// logsTailHandler 以 SSE 方式实时推送满足条件的日志，limit 大于 0 时先推送最近的 limit 条
+func logsTailHandler(writer http.ResponseWriter, request *http.Request) {
+	filter, err := parseLogFilter(request.URL.Query(), 0)
+	if err != nil {
+		writeAPIError(writer, err)
+		return
+	}
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		writeAPIError(writer, newAPIError(http.StatusInternalServerError, message.CodeInternal, "不支持事件推送"))
+		return
+	}
+
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.Header().Set("Connection", "keep-alive")
+
+	// 先订阅再查询，查询期间新增的日志按时间去重
+	ch := logger.Subscribe()
+	defer logger.Unsubscribe(ch)
+
+	var last int64
+	if filter.Limit > 0 {
+		for _, e := range logger.Query(filter) {
+			writeSSE(writer, logEvent(e))
+			last = e.Time
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-request.Context().Done():
+			return
+		case e := <-ch:
+			if e.Time <= last || !filter.Match(e) {
+				continue
+			}
+			writeSSE(writer, logEvent(e))
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(writer, ": ping\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+func logEvent(e message.LogEntry) message.Event {
+	return message.Event{Type: message.EventLog, Time: e.Time, Body: e}
+}
+
+// parseLogFilter 解析日志查询条件，level 为最低级别，since 为纳秒时间戳
+func parseLogFilter(query url.Values, defaultLimit int) (logger.Filter, error) {
+	filter := logger.Filter{
+		Proxy: query.Get("proxy"),
+		Limit: defaultLimit,
+	}
+
+	level, err := logger.ParseLevel(query.Get("level"))
+	if query.Get("level") == "" {
+		level = logger.LevelDebug
+	}
+	if err != nil {
+		return filter, newAPIError(http.StatusBadRequest, message.CodeInvalidRequest, err.Error())
+	}
+	filter.Level = level
+
+	switch source := query.Get("source"); source {
+	case "", message.LogSourceApp, message.LogSourceFrp:
+		filter.Source = source
+	default:
+		return filter, newAPIError(http.StatusBadRequest, message.CodeInvalidRequest, fmt.Sprintf("不支持的日志来源 %q，可选 app、frp", source))
+	}
+
+	if since := query.Get("since"); since != "" {
+		if filter.Since, err = strconv.ParseInt(since, 10, 64); err != nil {
+			return filter, newAPIError(http.StatusBadRequest, message.CodeInvalidRequest, fmt.Sprintf("since %q 应为纳秒时间戳", since))
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit < 0 {
+			return filter, newAPIError(http.StatusBadRequest, message.CodeInvalidRequest, fmt.Sprintf("limit %q 应为非负整数", limit))
+		}
+	}
+	return filter, nil
+}
+
+// wailsLogger 将 wails 运行时的日志转入 logger
+type wailsLogger struct{}
+
+func (wailsLogger) Print(msg string)   { logger.Info(msg, "component", "wails") }
+func (wailsLogger) Trace(msg string)   { logger.Debug(msg, "component", "wails") }
+func (wailsLogger) Debug(msg string)   { logger.Debug(msg, "component", "wails") }
+func (wailsLogger) Info(msg string)    { logger.Info(msg, "component", "wails") }
+func (wailsLogger) Warning(msg string) { logger.Warn(msg, "component", "wails") }
+func (wailsLogger) Error(msg string)   { logger.Error(msg, "component", "wails") }
+
+func (wailsLogger) Fatal(msg string) {
+	logger.Error(msg, "component", "wails")
+	os.Exit(1)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/douguohai/frp-client/logger"
+	"github.com/douguohai/frp-client/message"
+)
+
+func TestLogs(t *testing.T) {
+	e := integration(t)
+	port := freePort(t)
+	e.addProxy(t, message.ProxyMsg{
+		ProxyName:  "test-logs",
+		LocalPort:  e.echoPort,
+		RemotePort: port,
+	})
+	e.enable(t, "test-logs")
+	waitRunning(t, "test-logs")
+	e.call(t, "DELETE", "/api/v1/proxies/test-logs", nil, nil)
+
+	// frpc 的日志按远程代理名称还原出代理名称
+	var logs message.LogEntries
+	e.call(t, "GET", "/api/v1/logs?source=frp&proxy=test-logs&level=info&limit=0", nil, &logs)
+	if !hasLog(logs.Items, "start proxy success") {
+		t.Fatalf("没有 frpc 启动代理的日志: %+v", logs.Items)
+	}
+	e.call(t, "GET", "/api/logs?source=app&proxy=test-logs", nil, &logs)
+	if !hasLog(logs.Items, "新增代理") || !hasLog(logs.Items, "删除代理") {
+		t.Fatalf("没有新增和删除代理的日志: %+v", logs.Items)
+	}
+	e.callFails(t, "GET", "/api/v1/logs?level=verbose", nil)
+
+	data, err := os.ReadFile(filepath.Join(logDir(), logger.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"proxy":"test-logs"`) {
+		t.Fatal("日志文件中没有代理日志")
+	}
+	files, err := readStoreFiles(storeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name := range files {
+		if strings.HasPrefix(name, logDirName+"/") {
+			t.Fatalf("备份包含日志文件 %s", name)
+		}
+	}
+}
+
+// TestLogTail 实时推送只推送满足条件的日志
+func TestLogTail(t *testing.T) {
+	e := integration(t)
+	req, err := http.NewRequest("GET", e.srv.URL+"/api/v1/logs/tail?level=warn&proxy=test-tail", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+apiToken)
+	ctx, cancel := context.WithTimeout(context.Background(), testWait)
+	defer cancel()
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("状态码 %d", resp.StatusCode)
+	}
+
+	logger.Info("test 不推送", "proxy", "test-tail")
+	logger.Warn("test 其他代理", "proxy", "test")
+	logger.Warn("test 推送", "proxy", "test-tail")
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		var ev struct {
+			Type string           `json:"type"`
+			Body message.LogEntry `json:"body"`
+		}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev); err != nil {
+			t.Fatal(err)
+		}
+		if ev.Type != message.EventLog || ev.Body.Msg != "test 推送" {
+			t.Fatalf("推送了不满足条件的日志: %s", line)
+		}
+		return
+	}
+	t.Fatalf("没有收到推送的日志: %v", scanner.Err())
+}
+
+// hasLog 日志中是否有包含 msg 的记录
+func hasLog(entries []message.LogEntry, msg string) bool {
+	for _, entry := range entries {
+		if strings.Contains(entry.Msg, msg) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,10 +2,12 @@ package main
 
 import (
 	"embed"
-	"fmt"
+	"flag"
 	"net/http"
 	"regexp"
 
+	"github.com/douguohai/frp-client/logger"
+	"github.com/douguohai/frp-client/message"
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
 	"github.com/wailsapp/wails/v2/pkg/options/assetserver"
@@ -15,6 +17,29 @@ import (
 var assets embed.FS
 
 func main() {
+	flag.Parse()
+	initLogging()
+
+	if runOpenAPIFlags() {
+		return
+	}
+
+	// 打开本地存储，失败时界面展示错误页
+	if err := openStore(); err != nil {
+		logger.Error("打开数据目录失败", "err", err)
+		storeErr = err
+	} else {
+		initServices()
+		if err := loadAPIToken(); err != nil {
+			logger.Error("初始化接口令牌失败", "err", err)
+		}
+		if *metricsAddrFlag != "" {
+			if _, err := startMetrics(*metricsAddrFlag); err != nil {
+				logger.Error("开启 Prometheus 指标失败", "err", err)
+			}
+		}
+	}
+
 	// Create an instance of the app structure
 	app := NewApp()
 
@@ -26,21 +51,34 @@ func main() {
 		AssetServer: &assetserver.Options{
 			Assets: assets,
 			Middleware: func(next http.Handler) http.Handler {
-				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// 路由只构建一次
+				router := getLocalServerRoute()
 
-					router := getLocalServerRoute()
+				// 匹配以/api/开头的任意路径
+				apiPattern := regexp.MustCompile("^/api/.*$")
 
-					// 匹配以/api/开头的任意路径
-					apiPattern := regexp.MustCompile("^/api/.*$")
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+					if storeErr != nil {
+						if apiPattern.MatchString(r.URL.Path) {
+							writeAPIError(w, newAPIError(http.StatusServiceUnavailable, message.CodeStoreUnavailable, errStoreUnavailable.Error()+": "+storeErr.Error()))
+							return
+						}
+						if r.URL.Path == "/" || r.URL.Path == "/index.html" {
+							writeStoreErrorPage(w)
+							return
+						}
+					}
 
 					if apiPattern.MatchString(r.URL.Path) {
 						router.ServeHTTP(w, r)
 						return
 					}
 
-					// 在这里实现你的中间件逻辑
-					// 可以在请求被处理之前或之后执行一些处理
-					fmt.Println(r.RequestURI)
+					// 加载页面时下发会话 cookie，用于访问 /api
+					if r.URL.Path == "/" || r.URL.Path == "/index.html" {
+						issueSession(w)
+					}
 
 					// 执行下一个中间件或处理函数
 					next.ServeHTTP(w, r)
@@ -48,6 +86,7 @@ func main() {
 			},
 		},
 		BackgroundColour: &options.RGBA{R: 27, G: 38, B: 54, A: 1},
+		Logger:           wailsLogger{},
 		OnStartup:        app.startup,
 		OnBeforeClose:    app.shutdown,
 		Bind: []interface{}{
@@ -59,6 +98,6 @@ func main() {
 	})
 
 	if err != nil {
-		println("Error:", err.Error())
+		logger.Error("程序异常退出", "err", err)
 	}
 }
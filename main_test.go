@@ -0,0 +1,380 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/douguohai/frp-client/message"
+	"github.com/douguohai/frp-client/utils"
+	"github.com/fatedier/frp/pkg/config"
+	"github.com/fatedier/frp/server"
+)
+
+// 集成测试在本机启动 frps 和 echo 服务，通过 httptest 服务调用接口，-short 时跳过
+// frp v0.51.3 内部存在数据竞争，如客户端 Service.GracefulClose 与 keepControllerWorking 读写 ctl、
+// 服务端 Control 关闭 workConnCh 与 RegisterWorkConn 发送，均不经过本项目的代码，-race 时同样跳过
+
+const (
+	// testWait 等待代理生效或关闭的最长时间
+	testWait = 10 * time.Second
+	// testDeniedPort 不在 frps allow_ports 范围内的端口
+	testDeniedPort = 65000
+	// frps dashboard 账号
+	testDashboardUser     = "admin"
+	testDashboardPassword = "frps-dashboard-secret"
+)
+
+// env 集成测试环境，-short 或 -race 时为 nil
+var env *testEnv
+
+type testEnv struct {
+	srv           *httptest.Server
+	serverPort    int
+	dashboardPort int
+	echoPort      int
+}
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if testing.Short() || raceEnabled {
+		os.Exit(m.Run())
+	}
+	code, err := runIntegration(m)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "启动集成测试环境失败:", err)
+		os.Exit(1)
+	}
+	os.Exit(code)
+}
+
+// runIntegration 在临时数据目录初始化服务，连接本机 frps 后执行测试
+func runIntegration(m *testing.M) (int, error) {
+	dir, err := os.MkdirTemp("", "frp-client-test")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(dir)
+
+	initLogging()
+	*dataDirFlag = dir
+	if err := openStore(); err != nil {
+		return 0, err
+	}
+	initServices()
+	if err := loadAPIToken(); err != nil {
+		return 0, err
+	}
+	defer connection.Close()
+
+	e := &testEnv{srv: httptest.NewServer(getLocalServerRoute())}
+	defer e.srv.Close()
+	if e.serverPort, e.dashboardPort, err = startFrps(); err != nil {
+		return 0, fmt.Errorf("启动 frps: %w", err)
+	}
+	if e.echoPort, err = startEcho(); err != nil {
+		return 0, fmt.Errorf("启动 echo 服务: %w", err)
+	}
+	if err := e.do("POST", "/api/v1/server/connect", e.connectMsg(), nil); err != nil {
+		return 0, fmt.Errorf("连接 frps: %w", err)
+	}
+	env = e
+	return m.Run(), nil
+}
+
+// integration 返回集成测试环境，-short 或 -race 时跳过测试
+func integration(t *testing.T) *testEnv {
+	t.Helper()
+	if raceEnabled {
+		t.Skip("frp 内部存在数据竞争，-race 跳过集成测试")
+	}
+	if env == nil {
+		t.Skip("-short 跳过集成测试")
+	}
+	return env
+}
+
+// connectMsg 连接本机 frps 的配置
+func (e *testEnv) connectMsg() message.ConnectServerMsg {
+	return message.ConnectServerMsg{
+		ServerIp:          "127.0.0.1",
+		ServerPort:        e.serverPort,
+		DashboardURL:      fmt.Sprintf("127.0.0.1:%d", e.dashboardPort),
+		DashboardUser:     testDashboardUser,
+		DashboardPassword: testDashboardPassword,
+	}
+}
+
+// request 带接口令牌请求测试服务
+func (e *testEnv) request(method, path string, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		b, _ := json.Marshal(body)
+		reader = strings.NewReader(string(b))
+	}
+	req, err := http.NewRequest(method, e.srv.URL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+apiToken)
+	return http.DefaultClient.Do(req)
+}
+
+// do 调用接口，失败时返回响应中的错误信息
+func (e *testEnv) do(method, path string, body interface{}, data interface{}) error {
+	resp, err := e.request(method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var r struct {
+		Status int             `json:"status"`
+		Msg    string          `json:"msg"`
+		Data   json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &r); err != nil {
+		return fmt.Errorf("%s %s: %d %s", method, path, resp.StatusCode, raw)
+	}
+	if resp.StatusCode >= http.StatusBadRequest || r.Status != 0 {
+		return fmt.Errorf("%s %s: %d %s", method, path, resp.StatusCode, r.Msg)
+	}
+	if data != nil {
+		return json.Unmarshal(r.Data, data)
+	}
+	return nil
+}
+
+// call 调用接口，失败时结束测试
+func (e *testEnv) call(t *testing.T, method, path string, body interface{}, data interface{}) {
+	t.Helper()
+	if err := e.do(method, path, body, data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// callFails 调用接口，成功时结束测试
+func (e *testEnv) callFails(t *testing.T, method, path string, body interface{}) {
+	t.Helper()
+	if err := e.do(method, path, body, nil); err == nil {
+		t.Fatalf("%s %s 未返回错误", method, path)
+	}
+}
+
+// expectFieldErrors 调用接口并检查返回 422 及对应的字段错误
+func (e *testEnv) expectFieldErrors(t *testing.T, method, path string, body interface{}, fields ...string) {
+	t.Helper()
+	resp, err := e.request(method, path, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var r message.Response
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("%s %s: 状态码 %d，期望 422", method, path, resp.StatusCode)
+	}
+	got := map[string]bool{}
+	for _, f := range r.Errors {
+		got[f.Field] = true
+	}
+	for _, field := range fields {
+		if !got[field] {
+			t.Fatalf("缺少字段 %s 的错误: %+v", field, r.Errors)
+		}
+	}
+}
+
+// addProxy 新增代理，测试结束时删除
+func (e *testEnv) addProxy(t *testing.T, proxy message.ProxyMsg) message.ProxyMsgVo {
+	t.Helper()
+	var vo message.ProxyMsgVo
+	e.call(t, "POST", "/api/v1/proxies", proxy, &vo)
+	t.Cleanup(func() {
+		if len(proxies.Records(proxy.ProxyName)) != 0 {
+			proxies.Delete(guiActor, proxy.ProxyName)
+		}
+	})
+	return vo
+}
+
+// enable 开启代理
+func (e *testEnv) enable(t *testing.T, name string) {
+	t.Helper()
+	e.call(t, "POST", "/api/v1/proxies/"+name+"/enable", nil, nil)
+}
+
+// proxy 查询代理
+func (e *testEnv) proxy(t *testing.T, name string) message.ProxyMsgVo {
+	t.Helper()
+	var vo message.ProxyMsgVo
+	e.call(t, "GET", "/api/v1/proxies/"+name, nil, &vo)
+	return vo
+}
+
+// expectRunStatus 同步一次代理状态后检查实际运行状态
+func expectRunStatus(t *testing.T, name, want string) {
+	t.Helper()
+	proxies.SyncStatus()
+	records := proxies.Records(name)
+	if len(records) != 1 {
+		t.Fatalf("代理记录数为 %d", len(records))
+	}
+	if records[0].RunStatus != want {
+		t.Fatalf("代理运行状态为 %q，期望 %q", records[0].RunStatus, want)
+	}
+}
+
+// waitRecord 定时同步代理状态，等待代理记录满足条件
+func waitRecord(t *testing.T, name string, ok func(message.ProxyMsg) bool) message.ProxyMsg {
+	t.Helper()
+	var record message.ProxyMsg
+	for deadline := time.Now().Add(testWait); time.Now().Before(deadline); time.Sleep(200 * time.Millisecond) {
+		proxies.SyncStatus()
+		records := proxies.Records(name)
+		if len(records) != 1 {
+			t.Fatalf("代理记录数为 %d", len(records))
+		}
+		record = records[0]
+		if ok(record) {
+			return record
+		}
+	}
+	t.Fatalf("代理 %s 状态不符合预期: %+v", name, record)
+	return record
+}
+
+// waitRunning 等待代理运行
+func waitRunning(t *testing.T, name string) message.ProxyMsg {
+	t.Helper()
+	return waitRecord(t, name, func(p message.ProxyMsg) bool {
+		return p.RunStatus == "running"
+	})
+}
+
+// freePort 本机空闲端口
+func freePort(t *testing.T) int {
+	t.Helper()
+	port, err := utils.GetAvailablePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return port
+}
+
+// startFrps 在本机随机端口启动 frps
+// 返回 frps 端口和 dashboard 端口
+func startFrps() (int, int, error) {
+	port, err := utils.GetAvailablePort()
+	if err != nil {
+		return 0, 0, err
+	}
+	dashboardPort, err := utils.GetAvailablePort()
+	if err != nil {
+		return 0, 0, err
+	}
+	cfg := config.GetDefaultServerConf()
+	cfg.BindAddr = "127.0.0.1"
+	cfg.BindPort = port
+	cfg.LogLevel = "warn"
+	cfg.DashboardAddr = "127.0.0.1"
+	cfg.DashboardPort = dashboardPort
+	cfg.DashboardUser = testDashboardUser
+	cfg.DashboardPwd = testDashboardPassword
+	// 随机端口均小于 testDeniedPort
+	cfg.AllowPorts = map[int]struct{}{}
+	for p := 1024; p < testDeniedPort; p++ {
+		cfg.AllowPorts[p] = struct{}{}
+	}
+	cfg.Complete()
+	if err := cfg.Validate(); err != nil {
+		return 0, 0, err
+	}
+	svr, err := server.NewService(cfg)
+	if err != nil {
+		return 0, 0, err
+	}
+	go svr.Run(context.Background())
+	return port, dashboardPort, nil
+}
+
+// startEcho 在本机随机端口启动按行回显的 TCP 服务
+func startEcho() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go io.Copy(conn, conn)
+		}
+	}()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// echo 经远程端口发送一行数据并校验回显
+func echo(port int) error {
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", port), time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	want := fmt.Sprintf("ping %d\n", time.Now().UnixNano())
+	if _, err := conn.Write([]byte(want)); err != nil {
+		return err
+	}
+	got, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return fmt.Errorf("回显不一致: %q", got)
+	}
+	return nil
+}
+
+// waitEcho 等待远程端口可以回显
+func waitEcho(t *testing.T, port int) {
+	t.Helper()
+	var err error
+	for deadline := time.Now().Add(testWait); time.Now().Before(deadline); time.Sleep(200 * time.Millisecond) {
+		if err = echo(port); err == nil {
+			return
+		}
+	}
+	t.Fatalf("远程端口 %d 不通: %v", port, err)
+}
+
+// waitClosed 等待远程端口不再转发流量
+func waitClosed(t *testing.T, port int) {
+	t.Helper()
+	for deadline := time.Now().Add(testWait); time.Now().Before(deadline); time.Sleep(200 * time.Millisecond) {
+		if echo(port) != nil {
+			return
+		}
+	}
+	t.Fatalf("远程端口 %d 仍在转发", port)
+}
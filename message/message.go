@@ -16,6 +16,10 @@ type Msg struct {
 type ConnectServerMsg struct {
 	ServerIp   string `json:"serverIp"`   // 服务器IP
 	ServerPort int    `json:"serverPort"` // 服务器端口
+
+	DashboardURL      string `json:"dashboardUrl"`      // frps dashboard 地址，如 http://1.2.3.4:7500，为空时不查询
+	DashboardUser     string `json:"dashboardUser"`     // frps dashboard 用户名
+	DashboardPassword string `json:"dashboardPassword"` // frps dashboard 密码，用户名不变时留空表示保持原密码
 }
 
 // ProxyMsg 新增代理消息
@@ -29,8 +33,68 @@ type ProxyMsg struct {
 	RunStatus       string `json:"runStatus"`       //代理实际运行状态
 	AddTime         int64  `json:"addTime"`         //新增时间，排序用
 	RemoteAddr      string `json:"remote_addr"`     //远程访问地址
+	Err             string `json:"err"`             //frps 返回的启动错误
+	ErrReason       string `json:"errReason"`       //启动错误原因，见 ProxyErr* 常量
+	ProbePath       string `json:"probePath"`       //本地服务 HTTP 探测路径，为空时只探测 TCP 端口
+	ProbeStatus     int    `json:"probeStatus"`     //HTTP 探测期望的状态码，默认 200
+	LocalStatus     string `json:"localStatus"`     //本地服务状态，见 LocalStatus* 常量
+	LocalErr        string `json:"localErr"`        //本地服务探测失败原因
+
+	HealthCheck   HealthCheckConf `json:"healthCheck"`   //frp 健康检查配置
+	HealthStatus  string          `json:"healthStatus"`  //最近一次健康检查结果，见 HealthStatus* 常量
+	HealthCheckAt int64           `json:"healthCheckAt"` //健康检查结果变化的时间
+
+	Group    string `json:"group"`    //负载均衡组，同组代理共用远程端口，由 frps 轮流转发
+	GroupKey string `json:"groupKey"` //负载均衡组密钥，同组代理必须一致
+
+	ProxyProtocolVersion string `json:"proxyProtocolVersion"` //向本地服务发送的 PROXY 协议版本，空为不发送，v1 或 v2
+
+	Stats bool `json:"stats"` //统计流量，仅 tcp，开启后由插件代替 frpc 连接本地服务
+
+	Schedule ScheduleConf `json:"schedule"` //定时开关
+
+	ExpireAt     int64  `json:"expireAt"`           //到期时间，纳秒时间戳，0 为不到期
+	ExpireAction string `json:"expireAction"`       //到期操作，见 ExpireAction* 常量，空为关闭
+	ExpireIn     string `json:"expireIn,omitempty"` //有效时长，如 30m、2h，仅新增、修改时传入，优先于 expireAt，保存为 expireAt
+}
+
+// 代理到期操作
+const (
+	ExpireActionDisable string = "disable" // 到期关闭，默认
+	ExpireActionDelete  string = "delete"  // 到期删除
+)
+
+// ProxyExpiry 开启代理时设置到期，expireIn 优先于 expireAt，均为空时保持原有设置
+type ProxyExpiry struct {
+	ExpireIn     string `json:"expireIn"`     //有效时长，如 30m、2h
+	ExpireAt     int64  `json:"expireAt"`     //到期时间，纳秒时间戳
+	ExpireAction string `json:"expireAction"` //到期操作 disable、delete，为空时保持原有设置
+}
+
+// ScheduleConf 代理定时开关，cron 与 windows 二选一，均为空时不启用
+// 当前时间落在范围内时开启，范围外关闭；手动开关保持到下一次切换
+type ScheduleConf struct {
+	Cron     string `json:"cron"`     //cron 表达式 "分 时 日 月 周"，命中的分钟内开启，如 "* 9-17 * * 1-5"
+	Windows  string `json:"windows"`  //时间窗口，多个用分号分隔，如 "mon-fri 09:00-18:00; sat 10:00-12:00"，省略星期为每天
+	Timezone string `json:"timezone"` //IANA 时区，如 Asia/Shanghai，为空时使用本机时区
 }
 
+// HealthCheckConf frp 健康检查配置，连续失败 maxFailed 次后代理下线，恢复后自动重新上线
+// 数值为 0 时使用 frp 默认值：超时 3 秒，间隔 10 秒，失败 1 次
+type HealthCheckConf struct {
+	Type      string `json:"type"`      //检查方式，空为不检查，tcp 或 http
+	TimeoutS  int    `json:"timeoutS"`  //单次检查超时秒数
+	MaxFailed int    `json:"maxFailed"` //连续失败多少次后下线
+	IntervalS int    `json:"intervalS"` //检查间隔秒数
+	URL       string `json:"url"`       //http 检查的路径，如 /health，返回 200 视为正常
+}
+
+// 健康检查结果，未开启健康检查的代理为空
+const (
+	HealthStatusPassed string = "passed" // 检查通过
+	HealthStatusFailed string = "failed" // 检查失败，代理已下线
+)
+
 // ProxyMsgVo 代理展示消息
 type ProxyMsgVo struct {
 	ProxyName  string `json:"proxyName"`
@@ -39,7 +103,184 @@ type ProxyMsgVo struct {
 	RemotePort int    `json:"remotePort"`
 	Status     bool   `json:"status"`
 	RemoteAddr string `json:"remoteAddr"`
-	AddTime    int64  `json:"addTime"` //新增时间，排序用
+	AddTime    int64  `json:"addTime"`   //新增时间，排序用
+	Err        string `json:"err"`       //frps 返回的启动错误
+	ErrReason  string `json:"errReason"` //启动错误原因
+	RunStatus  string `json:"runStatus"` //frp 代理运行状态
+
+	ProbePath   string `json:"probePath"`   //本地服务 HTTP 探测路径
+	ProbeStatus int    `json:"probeStatus"` //HTTP 探测期望的状态码
+	LocalStatus string `json:"localStatus"` //本地服务状态
+	LocalErr    string `json:"localErr"`    //本地服务探测失败原因
+
+	HealthCheck   HealthCheckConf `json:"healthCheck"`   //frp 健康检查配置
+	HealthStatus  string          `json:"healthStatus"`  //最近一次健康检查结果
+	HealthCheckAt int64           `json:"healthCheckAt"` //健康检查结果变化的时间
+
+	Group       string `json:"group"`       //负载均衡组
+	HasGroupKey bool   `json:"hasGroupKey"` //是否设置了负载均衡组密钥，密钥只写不返回
+
+	ProxyProtocolVersion string `json:"proxyProtocolVersion"` //PROXY 协议版本
+
+	Stats bool `json:"stats"` //是否统计流量
+
+	Schedule       ScheduleConf `json:"schedule"`       //定时开关
+	NextTransition int64        `json:"nextTransition"` //下一次定时切换的时间，没有定时或一周内不切换时为 0
+	NextStatus     bool         `json:"nextStatus"`     //下一次定时切换后的开启状态
+
+	ExpireAt     int64  `json:"expireAt"`     //到期时间，0 为不到期
+	ExpireAction string `json:"expireAction"` //到期操作
+	ExpiresInS   int64  `json:"expiresInS"`   //距到期的秒数，不到期时为 0
+
+	// 以下来自 frps dashboard，未配置 dashboard 或 frps 上没有该代理时为空
+	ServerStatus    string `json:"serverStatus"`    //frps 上的代理状态 online、offline
+	TodayTrafficIn  int64  `json:"todayTrafficIn"`  //frps 统计的今日流入字节数
+	TodayTrafficOut int64  `json:"todayTrafficOut"` //frps 统计的今日流出字节数
+	ServerConns     int64  `json:"serverConns"`     //frps 上的当前连接数
+	LastStartTime   string `json:"lastStartTime"`   //frps 记录的最近启动时间
+	LastCloseTime   string `json:"lastCloseTime"`   //frps 记录的最近关闭时间
+}
+
+// ProxyGroup 负载均衡组，members 为参与该组的本地代理
+type ProxyGroup struct {
+	Group      string   `json:"group"`      //组名
+	Type       string   `json:"type"`       //代理类型
+	RemotePort int      `json:"remotePort"` //远程端口
+	Members    []string `json:"members"`    //本地代理名称，按添加时间排序
+	Enabled    int      `json:"enabled"`    //已开启的成员数
+}
+
+type ProxyGroups struct {
+	Items []ProxyGroup `json:"rows"`
+}
+
+// 本地服务状态，未开启或无法探测的代理为空
+const (
+	LocalStatusUp   string = "up"   // 本地服务可访问
+	LocalStatusDown string = "down" // 本地服务不可访问
+)
+
+// 代理启动错误原因
+const (
+	ProxyErrPortInUse       string = "port_in_use"         // 远程端口已被占用
+	ProxyErrPortNotAllowed  string = "port_not_allowed"    // 远程端口不在 frps allow_ports 范围内
+	ProxyErrQuotaExceeded   string = "quota_exceeded"      // 超出 frps max_ports_per_client 限制
+	ProxyErrNoAvailablePort string = "no_available_port"   // frps 没有可分配的端口
+	ProxyErrNameConflict    string = "name_conflict"       // frps 上已存在同名代理
+	ProxyErrHealthCheck     string = "health_check_failed" // 健康检查失败，代理已下线
+	ProxyErrGroupConflict   string = "group_conflict"      // 负载均衡组密钥或远程端口与 frps 上的同组代理不一致
+	ProxyErrUnknown         string = "unknown"             // 其他错误
+)
+
+// ProtocolTest PROXY 协议测试监听状态
+// 测试期间在本地端口上监听并解析 PROXY 协议头，用于确认真实访问地址能否到达本地服务
+type ProtocolTest struct {
+	ProxyName string           `json:"proxyName"` //代理名称
+	LocalPort int              `json:"localPort"` //监听的本地端口
+	Running   bool             `json:"running"`   //是否正在监听
+	ExpiresAt int64            `json:"expiresAt"` //自动停止的时间
+	Items     []ProtocolRecord `json:"rows"`      //最近收到的连接，按时间倒序
+}
+
+// ProtocolRecord 测试监听收到的一次连接
+type ProtocolRecord struct {
+	Time       int64  `json:"time"`       //收到连接的时间
+	Version    int    `json:"version"`    //PROXY 协议版本，1 或 2，未收到协议头为 0
+	SourceAddr string `json:"sourceAddr"` //协议头中的访问者地址
+	DestAddr   string `json:"destAddr"`   //协议头中的目标地址
+	PeerAddr   string `json:"peerAddr"`   //TCP 连接的对端地址，一般为 frpc
+	Err        string `json:"err"`        //协议头解析失败原因
+}
+
+// ProxyStats 代理流量统计，只统计 tcp 代理，流入为访问者发往本地服务的数据
+type ProxyStats struct {
+	ProxyName  string       `json:"proxyName"`  //代理名称
+	BytesIn    int64        `json:"bytesIn"`    //累计流入字节数
+	BytesOut   int64        `json:"bytesOut"`   //累计流出字节数
+	TotalConns int64        `json:"totalConns"` //累计连接数
+	CurConns   int64        `json:"curConns"`   //当前连接数
+	Items      []StatsPoint `json:"rows"`       //时间序列，按时间排序
+}
+
+// StatsPoint 一次采样，字节数和连接数为距上次采样的增量
+type StatsPoint struct {
+	Time     int64 `json:"time"`     //采样时间
+	BytesIn  int64 `json:"bytesIn"`  //流入字节数
+	BytesOut int64 `json:"bytesOut"` //流出字节数
+	NewConns int64 `json:"newConns"` //新建连接数
+	CurConns int64 `json:"curConns"` //采样时的连接数
+}
+
+// 日志来源
+const (
+	LogSourceApp string = "app" // 本程序
+	LogSourceFrp string = "frp" // 内置的 frpc
+)
+
+// LogEntry 一条日志，日志文件中每行一条
+type LogEntry struct {
+	Time   int64             `json:"time"`             //记录时间
+	Level  string            `json:"level"`            //级别 debug、info、warn、error
+	Source string            `json:"source"`           //来源 app、frp
+	Proxy  string            `json:"proxy,omitempty"`  //相关的代理名称
+	Msg    string            `json:"msg"`              //日志内容
+	Fields map[string]string `json:"fields,omitempty"` //附加字段
+}
+
+// LogEntries 日志查询结果，按时间排序
+type LogEntries struct {
+	Items []LogEntry `json:"rows"`
+}
+
+// 审计操作来源
+const (
+	AuditSourceGUI    string = "gui"    // 界面，包括会话 cookie 请求和 wails 绑定方法
+	AuditSourceAPI    string = "api"    // 携带接口令牌的请求，如脚本和命令行工具
+	AuditSourceSystem string = "system" // 程序自动执行
+)
+
+// 审计操作
+const (
+	AuditActionAdd       string = "add"        // 新增代理
+	AuditActionEdit      string = "edit"       // 修改代理
+	AuditActionDelete    string = "delete"     // 删除代理
+	AuditActionEnable    string = "enable"     // 开启代理
+	AuditActionDisable   string = "disable"    // 关闭代理
+	AuditActionConnect   string = "connect"    // 设置服务器并连接
+	AuditActionSetServer string = "set_server" // 只设置服务器，不连接
+	AuditActionUnlock    string = "unlock"     // 断开服务器并解锁配置
+	AuditActionRestore   string = "restore"    // 恢复备份
+)
+
+// AuditActor 操作来源
+type AuditActor struct {
+	Source     string `json:"source"`     // 来源，见 AuditSource* 常量
+	Client     string `json:"client"`     // 请求的 User-Agent，wails 绑定方法为 wails
+	RemoteAddr string `json:"remoteAddr"` // 请求的对端地址
+}
+
+// AuditRecord 一条审计记录，只追加不修改
+type AuditRecord struct {
+	ID     string `json:"id"`     // 记录编号，按时间递增
+	Time   int64  `json:"time"`   // 操作时间
+	Action string `json:"action"` // 操作，见 AuditAction* 常量
+	Target string `json:"target"` // 操作对象，代理名称或服务器地址
+	AuditActor
+
+	Before interface{} `json:"before"`        // 操作前的配置，新增时为空；密钥类字段已隐藏
+	After  interface{} `json:"after"`         // 操作后的配置，删除时为空
+	Err    string      `json:"err,omitempty"` // 操作失败原因，如连接失败
+}
+
+// AuditRecords 审计记录查询结果，按时间排序
+type AuditRecords struct {
+	Items []AuditRecord `json:"rows"`
+}
+
+// FreePort 查找到的空闲远程端口
+type FreePort struct {
+	Type string `json:"type"` // 代理类型
+	Port int    `json:"port"` // 远程端口
 }
 
 type ProxyMsgVos struct {
@@ -73,30 +314,162 @@ type ProxyStatus struct {
 	Status    bool   `json:"status"`
 }
 
-// InnerProxyStatus 内部代理状态
-type InnerProxyStatus struct {
-	TCP []TCPProxyStatsu `json:"tcp"`
-}
-
-// TCPProxyStatus
-type TCPProxyStatsu struct {
-	Name       string `json:"name"`
-	Type       string `json:"type"`
-	Status     string `json:"status"`
-	Err        string `json:"err"`
-	LocalAddr  string `json:"local_addr"`
-	Plugin     string `json:"plugin"`
-	RemoteAddr string `json:"remote_addr"`
-}
-
 type ServiceInfo struct {
 	ServerIp   string `json:"serverIp"`
 	ServerPort int    `json:"serverPort"`
 	RunStatus  int64  `json:"runStatus"` //0 未链接 1 已连接 -1 尝试连接中
 	Time       int64  `json:"time"`
+
+	DashboardURL  string `json:"dashboardUrl"`  //frps dashboard 地址，不返回密码
+	DashboardUser string `json:"dashboardUser"` //frps dashboard 用户名
+}
+
+// DashboardInfo frps dashboard 返回的服务器信息
+type DashboardInfo struct {
+	Version         string           `json:"version"`         //frps 版本
+	ClientCounts    int64            `json:"clientCounts"`    //在线客户端数
+	CurConns        int64            `json:"curConns"`        //当前连接数
+	TotalTrafficIn  int64            `json:"totalTrafficIn"`  //今日流入字节数
+	TotalTrafficOut int64            `json:"totalTrafficOut"` //今日流出字节数
+	ProxyTypeCounts map[string]int64 `json:"proxyTypeCounts"` //各类型的在线代理数
 }
 
 type ServiceResult struct {
 	Result
 	Data ServiceInfo `json:"data"`
 }
+
+// BackupManifest 备份包元数据
+type BackupManifest struct {
+	FormatVersion int              `json:"formatVersion"` // 备份格式版本
+	AppVersion    string           `json:"appVersion"`    // 客户端版本
+	FrpVersion    string           `json:"frpVersion"`    // frp 版本
+	CreateTime    int64            `json:"createTime"`    // 备份时间
+	Server        ConnectServerMsg `json:"server"`        // 服务器配置，不含 dashboard 密码
+	Files         []string         `json:"files"`         // 备份包含的存储文件
+}
+
+// RestoreReport 恢复结果报告
+type RestoreReport struct {
+	Mode          string         `json:"mode"`          // overwrite 覆盖 / merge 合并
+	DryRun        bool           `json:"dryRun"`        // 仅校验，不写入
+	Manifest      BackupManifest `json:"manifest"`      // 备份包元数据
+	Added         []string       `json:"added"`         // 新增记录，collection/resource
+	Updated       []string       `json:"updated"`       // 内容变更的记录
+	Removed       []string       `json:"removed"`       // 被移除的记录，仅覆盖模式
+	Unchanged     []string       `json:"unchanged"`     // 未变化的记录
+	ServerApplied bool           `json:"serverApplied"` // 是否应用了备份中的服务器配置
+	PreviousStore string         `json:"previousStore"` // 恢复前存储的保留目录
+}
+
+const (
+	EventProxy      string = "proxy"          // 代理状态变化
+	EventConnection string = "connection"     // 服务器连接状态变化
+	EventError      string = "error"          // 运行错误
+	EventLog        string = "log"            // 日志，仅日志实时推送接口
+	EventExpiring   string = "proxy_expiring" // 代理即将到期
+)
+
+// Event 实时推送事件
+type Event struct {
+	Type string      `json:"type"` // 事件类型
+	Time int64       `json:"time"` // 事件时间
+	Body interface{} `json:"body"` // 事件内容
+}
+
+// ProxyEvent 代理状态变化事件
+type ProxyEvent struct {
+	ProxyName  string `json:"proxyName"`  // 本地代理名称
+	Status     bool   `json:"status"`     // 代理预期运行状态
+	RunStatus  string `json:"runStatus"`  // 代理实际运行状态
+	RemoteAddr string `json:"remoteAddr"` // 远程访问地址
+	Err        string `json:"err"`        // 启动错误
+	ErrReason  string `json:"errReason"`  // 启动错误原因
+
+	LocalStatus string `json:"localStatus"` // 本地服务状态
+	LocalErr    string `json:"localErr"`    // 本地服务探测失败原因
+
+	HealthStatus string `json:"healthStatus"` // 最近一次健康检查结果
+
+	Deleted bool `json:"deleted,omitempty"` // 代理已删除
+}
+
+// ProxyExpiringEvent 代理即将到期事件，每个到期时间只推送一次
+type ProxyExpiringEvent struct {
+	ProxyName    string `json:"proxyName"`    // 本地代理名称
+	ExpireAt     int64  `json:"expireAt"`     // 到期时间
+	ExpireAction string `json:"expireAction"` // 到期操作
+	ExpiresInS   int64  `json:"expiresInS"`   // 距到期的秒数
+}
+
+// ErrorEvent 错误事件
+type ErrorEvent struct {
+	Source    string `json:"source"`    // 错误来源 connect / proxy
+	ProxyName string `json:"proxyName"` // 相关代理，可为空
+	Msg       string `json:"msg"`       // 错误信息
+}
+
+// v1 接口错误码
+const (
+	CodeOK                  string = "ok"                    // 成功
+	CodeInvalidRequest      string = "invalid_request"       // 请求格式错误
+	CodeUnauthorized        string = "unauthorized"          // 未认证
+	CodeForbidden           string = "forbidden"             // 无权访问
+	CodeNotFound            string = "not_found"             // 资源不存在
+	CodeConflict            string = "conflict"              // 资源已存在
+	CodeValidationFailed    string = "validation_failed"     // 参数校验失败
+	CodeServerNotConfigured string = "server_not_configured" // 未配置服务器
+	CodeConnectFailed       string = "connect_failed"        // 连接服务器失败
+	CodeDashboardFailed     string = "dashboard_failed"      // 未配置或无法访问 frps dashboard
+	CodeStoreUnavailable    string = "store_unavailable"     // 本地存储不可用
+	CodeInternal            string = "internal_error"        // 内部错误
+)
+
+// Response v1 接口统一响应，status 与 amis 约定一致，0 为成功
+type Response struct {
+	Status int         `json:"status"` // 0 成功 -1 失败
+	Code   string      `json:"code"`   // 机器可读错误码
+	Msg    string      `json:"msg"`    // 提示信息
+	Data   interface{} `json:"data"`   // 响应数据
+
+	Errors []FieldError `json:"errors,omitempty"` // 参数校验失败时的字段错误
+}
+
+// FieldError 字段校验错误，field 与请求中的字段名一致，便于界面就地展示
+type FieldError struct {
+	Field string `json:"field"` // 字段名
+	Msg   string `json:"msg"`   // 错误信息
+}
+
+// ProxyPatch 修改代理，只修改传入的字段
+type ProxyPatch struct {
+	ProxyName  *string `json:"proxyName"`  //代理名称，修改即改名
+	Type       *string `json:"type"`       //代理类型 tcp、udp
+	LocalPort  *int    `json:"localPort"`  //本地端口
+	RemotePort *int    `json:"remotePort"` //远程端口
+
+	ProbePath   *string `json:"probePath"`   //本地服务 HTTP 探测路径，空字符串为只探测 TCP 端口
+	ProbeStatus *int    `json:"probeStatus"` //HTTP 探测期望的状态码
+
+	HealthCheck *HealthCheckConf `json:"healthCheck"` //frp 健康检查配置，整体替换
+
+	Group    *string `json:"group"`    //负载均衡组，空字符串为退出分组
+	GroupKey *string `json:"groupKey"` //负载均衡组密钥，组名不变时空字符串为保持原密钥
+
+	ProxyProtocolVersion *string `json:"proxyProtocolVersion"` //PROXY 协议版本，空字符串为不发送
+
+	Stats *bool `json:"stats"` //是否统计流量
+
+	Schedule *ScheduleConf `json:"schedule"` //定时开关，整体替换，各项为空为取消定时
+
+	ExpireAt     *int64  `json:"expireAt"`     //到期时间，0 为取消到期
+	ExpireIn     *string `json:"expireIn"`     //有效时长，从修改时算起
+	ExpireAction *string `json:"expireAction"` //到期操作
+}
+
+// ProxyEditMsg 旧接口修改代理，只修改请求中出现的字段
+// originName 为修改前的名称，为空时按 proxyName 查找
+type ProxyEditMsg struct {
+	ProxyPatch
+	OriginName string `json:"originName"`
+}
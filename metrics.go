@@ -0,0 +1,148 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/douguohai/frp-client/logger"
+	"github.com/douguohai/frp-client/message"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var metricsAddrFlag = flag.String("metrics-addr", "", "Prometheus /metrics 的监听地址，如 127.0.0.1:9180，为空时不开启；该接口不校验令牌")
+
+var (
+	// metricsRegistry 独立的注册表，不混入 frp 注册到默认注册表的指标
+	metricsRegistry = prometheus.NewRegistry()
+
+	apiRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "frp_client_api_request_duration_seconds",
+		Help:    "本地接口请求耗时",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "code"})
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		apiRequestDuration,
+		stateCollector{},
+	)
+}
+
+// startMetrics 开启 /metrics 监听，返回实际监听的地址
+func startMetrics(addr string) (net.Addr, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	router := http.NewServeMux()
+	router.Handle("/metrics", promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{}))
+	go func() {
+		if err := http.Serve(l, router); err != nil {
+			logger.Error("Prometheus 指标服务退出", "err", err)
+		}
+	}()
+	logger.Info("Prometheus 指标监听", "url", fmt.Sprintf("http://%s/metrics", l.Addr()))
+	return l.Addr(), nil
+}
+
+// metricsMiddleware 按路由模板统计接口耗时，事件和日志推送为长连接，不统计
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		route := "unknown"
+		if r := mux.CurrentRoute(request); r != nil {
+			if tpl, err := r.GetPathTemplate(); err == nil {
+				route = tpl
+			}
+		}
+		switch route {
+		case "/api/events", "/api/v1/events", "/api/logs/tail", "/api/v1/logs/tail":
+			next.ServeHTTP(writer, request)
+			return
+		}
+
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: writer, status: http.StatusOK}
+		next.ServeHTTP(sw, request)
+		apiRequestDuration.WithLabelValues(request.Method, route, strconv.Itoa(sw.status)).Observe(time.Since(start).Seconds())
+	})
+}
+
+// statusWriter 记录响应状态码
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+var (
+	connectionStateDesc = prometheus.NewDesc("frp_client_connection_state", "与 frps 的连接状态，0 未连接，1 已连接，-1 连接中", nil, nil)
+	reconnectsDesc      = prometheus.NewDesc("frp_client_reconnects_total", "启动以来重新登录 frps 的次数", nil, nil)
+	proxyEnabledDesc    = prometheus.NewDesc("frp_client_proxy_enabled", "代理是否开启", []string{"proxy", "type"}, nil)
+	proxyPhaseDesc      = prometheus.NewDesc("frp_client_proxy_phase", "代理在 frpc 中的运行状态，当前状态为 1", []string{"proxy", "phase"}, nil)
+	proxyLocalUpDesc    = prometheus.NewDesc("frp_client_proxy_local_up", "本地服务探测结果，1 可访问，0 不可访问，未探测时没有该指标", []string{"proxy"}, nil)
+	proxyBytesInDesc    = prometheus.NewDesc("frp_client_proxy_bytes_in_total", "流入本地服务的字节数", []string{"proxy"}, nil)
+	proxyBytesOutDesc   = prometheus.NewDesc("frp_client_proxy_bytes_out_total", "从本地服务流出的字节数", []string{"proxy"}, nil)
+	proxyConnsDesc      = prometheus.NewDesc("frp_client_proxy_connections", "当前连接数", []string{"proxy"}, nil)
+	proxyConnsTotalDesc = prometheus.NewDesc("frp_client_proxy_connections_total", "累计连接数", []string{"proxy"}, nil)
+)
+
+// stateCollector 抓取时读取连接和代理的当前状态
+type stateCollector struct{}
+
+func (stateCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		connectionStateDesc, reconnectsDesc, proxyEnabledDesc, proxyPhaseDesc, proxyLocalUpDesc,
+		proxyBytesInDesc, proxyBytesOutDesc, proxyConnsDesc, proxyConnsTotalDesc,
+	} {
+		ch <- desc
+	}
+}
+
+func (stateCollector) Collect(ch chan<- prometheus.Metric) {
+	if connection == nil || proxies == nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(connectionStateDesc, prometheus.GaugeValue, float64(connection.RunStatus()))
+	ch <- prometheus.MustNewConstMetric(reconnectsDesc, prometheus.CounterValue, float64(connection.Reconnects()))
+
+	for _, proxy := range proxies.Records("") {
+		name := proxy.ProxyName
+		ch <- prometheus.MustNewConstMetric(proxyEnabledDesc, prometheus.GaugeValue, boolValue(proxy.Status), name, proxy.Type)
+		if proxy.RunStatus != "" {
+			ch <- prometheus.MustNewConstMetric(proxyPhaseDesc, prometheus.GaugeValue, 1, name, proxy.RunStatus)
+		}
+		if proxy.LocalStatus != "" {
+			ch <- prometheus.MustNewConstMetric(proxyLocalUpDesc, prometheus.GaugeValue, boolValue(proxy.LocalStatus == message.LocalStatusUp), name)
+		}
+
+		stats, err := proxies.Stats(name, time.Minute)
+		if err != nil {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(proxyBytesInDesc, prometheus.CounterValue, float64(stats.BytesIn), name)
+		ch <- prometheus.MustNewConstMetric(proxyBytesOutDesc, prometheus.CounterValue, float64(stats.BytesOut), name)
+		ch <- prometheus.MustNewConstMetric(proxyConnsDesc, prometheus.GaugeValue, float64(stats.CurConns), name)
+		ch <- prometheus.MustNewConstMetric(proxyConnsTotalDesc, prometheus.CounterValue, float64(stats.TotalConns), name)
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
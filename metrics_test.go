@@ -0,0 +1,54 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/douguohai/frp-client/message"
+)
+
+func TestMetrics(t *testing.T) {
+	e := integration(t)
+	addr, err := startMetrics("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := freePort(t)
+	e.addProxy(t, message.ProxyMsg{
+		ProxyName:  "test-metrics",
+		LocalPort:  e.echoPort,
+		RemotePort: port,
+		Stats:      true,
+	})
+	e.enable(t, "test-metrics")
+	waitEcho(t, port)
+	waitRunning(t, "test-metrics")
+	proxies.ProbeLocal()
+
+	resp, err := http.Get("http://" + addr.String() + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"frp_client_connection_state 1",
+		"frp_client_reconnects_total",
+		`frp_client_proxy_phase{phase="running",proxy="test-metrics"} 1`,
+		`frp_client_proxy_local_up{proxy="test-metrics"} 1`,
+		`frp_client_proxy_connections_total{proxy="test-metrics"}`,
+		`frp_client_api_request_duration_seconds_count{code="201",method="POST",route="/api/v1/proxies"}`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("指标中缺少 %s", want)
+		}
+	}
+	if strings.Contains(string(body), `frp_client_proxy_bytes_in_total{proxy="test-metrics"} 0`) {
+		t.Error("流入字节数为 0")
+	}
+}
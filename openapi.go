@@ -0,0 +1,447 @@
+package main
+
+//go:generate go run . -openapi frontend/openapi.json
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/douguohai/frp-client/message"
+	"github.com/gorilla/mux"
+)
+
+var (
+	openapiOutFlag   = flag.String("openapi", "", "输出 OpenAPI 文档到指定文件后退出，- 为标准输出")
+	openapiCheckFlag = flag.String("openapi-check", "", "校验指定的 OpenAPI 文档与当前代码一致后退出")
+)
+
+// apiRoute 接口文档描述
+type apiRoute struct {
+	method  string
+	path    string
+	summary string
+	tag     string
+	// query 可选的查询参数
+	query []string
+	// request 请求体类型，nil 表示无请求体
+	request interface{}
+	// optional 请求体可省略
+	optional bool
+	// upload 请求体为文件上传的类型，如 application/zip
+	upload string
+	// response 响应类型，v1 接口为 Response.data 的类型
+	response interface{}
+	// contentType 非 JSON 响应的类型
+	contentType string
+	// status 成功时的 HTTP 状态码，默认 200
+	status int
+	// plain 响应不使用统一的 Response，如旧接口、SSE 和文件下载
+	plain bool
+}
+
+// apiRoutes 全部接口，新增路由时需同步维护，-openapi-check 会校验与路由注册一致
+var apiRoutes = []apiRoute{
+	{method: "GET", path: "/api/getProxy", summary: "获取代理列表", tag: "legacy", response: message.ProxyResult{}, plain: true},
+	{method: "POST", path: "/api/addProxy", summary: "新增代理", tag: "legacy", request: message.ProxyMsg{}, response: message.Result{}, plain: true},
+	{method: "POST", path: "/api/editProxy", summary: "修改代理", tag: "legacy", request: message.ProxyEditMsg{}, response: message.Result{}, plain: true},
+	{method: "POST", path: "/api/delProxy", summary: "删除代理", tag: "legacy", request: message.ProxyMsg{}, response: message.AjaxResult{}, plain: true},
+	{method: "PUT", path: "/api/openProxy", summary: "开启或关闭代理", tag: "legacy", request: message.ProxyStatus{}, response: message.AjaxResult{}, plain: true},
+	{method: "POST", path: "/api/connect", summary: "连接服务器", tag: "legacy", request: message.ConnectServerMsg{}, response: message.ResultC{}, plain: true},
+	{method: "POST", path: "/api/unlock", summary: "断开服务器并解锁配置", tag: "legacy", response: message.AjaxResult{}, plain: true},
+	{method: "GET", path: "/api/unlock", summary: "断开服务器并解锁配置，兼容旧版，使用会话 cookie 时需携带 csrf 头", tag: "legacy", response: message.AjaxResult{}, plain: true},
+	{method: "GET", path: "/api/getServer", summary: "获取服务器连接状态", tag: "legacy", response: message.ServiceResult{}, plain: true},
+	{method: "GET", path: "/api/events", summary: "事件推送（SSE）", tag: "legacy", response: message.Event{}, contentType: "text/event-stream", plain: true},
+	{method: "GET", path: "/api/backup", summary: "导出备份", tag: "legacy", contentType: "application/zip", plain: true},
+	{method: "POST", path: "/api/restore", summary: "恢复备份", tag: "legacy", query: []string{"mode", "dryRun"}, upload: "application/zip", response: message.RestoreReport{}},
+	{method: "GET", path: "/api/proxies/{name}/stats", summary: "获取代理流量统计", tag: "proxies", query: []string{"window"}, response: message.ProxyStats{}},
+	{method: "GET", path: "/api/logs", summary: "查询最近的日志", tag: "logs", query: []string{"level", "proxy", "source", "since", "limit"}, response: message.LogEntries{}},
+	{method: "GET", path: "/api/audit", summary: "查询或导出配置变更审计记录，format 为 csv、jsonl 时以附件下载", tag: "audit", query: []string{"action", "target", "source", "since", "until", "limit", "format"}, response: message.AuditRecords{}},
+	{method: "GET", path: "/api/logs/tail", summary: "实时推送日志（SSE）", tag: "logs", query: []string{"level", "proxy", "source", "limit"}, response: message.Event{}, contentType: "text/event-stream", plain: true},
+	{method: "GET", path: "/api/openapi.json", summary: "OpenAPI 文档", tag: "meta", contentType: "application/json", plain: true},
+
+	{method: "GET", path: "/api/v1/proxies", summary: "获取代理列表", tag: "proxies", response: message.ProxyMsgVos{}},
+	{method: "POST", path: "/api/v1/proxies", summary: "新增代理", tag: "proxies", request: message.ProxyMsg{}, response: message.ProxyMsgVo{}, status: http.StatusCreated},
+	{method: "GET", path: "/api/v1/proxies/{name}", summary: "获取代理", tag: "proxies", response: message.ProxyMsgVo{}},
+	{method: "PATCH", path: "/api/v1/proxies/{name}", summary: "修改代理", tag: "proxies", request: message.ProxyPatch{}, response: message.ProxyMsgVo{}},
+	{method: "DELETE", path: "/api/v1/proxies/{name}", summary: "删除代理", tag: "proxies"},
+	{method: "POST", path: "/api/v1/proxies/{name}/enable", summary: "开启代理，可同时设置到期，不传请求体时保持原有到期设置", tag: "proxies", request: message.ProxyExpiry{}, optional: true, response: message.ProxyMsgVo{}},
+	{method: "POST", path: "/api/v1/proxies/{name}/disable", summary: "关闭代理", tag: "proxies", response: message.ProxyMsgVo{}},
+	{method: "GET", path: "/api/v1/proxies/{name}/protocol-test", summary: "获取 PROXY 协议测试监听状态", tag: "proxies", response: message.ProtocolTest{}},
+	{method: "POST", path: "/api/v1/proxies/{name}/protocol-test", summary: "启动 PROXY 协议测试监听", tag: "proxies", response: message.ProtocolTest{}},
+	{method: "DELETE", path: "/api/v1/proxies/{name}/protocol-test", summary: "停止 PROXY 协议测试监听", tag: "proxies", response: message.ProtocolTest{}},
+	{method: "GET", path: "/api/v1/proxies/{name}/stats", summary: "获取代理流量统计", tag: "proxies", query: []string{"window"}, response: message.ProxyStats{}},
+	{method: "GET", path: "/api/v1/groups", summary: "获取负载均衡组列表", tag: "proxies", response: message.ProxyGroups{}},
+	{method: "GET", path: "/api/v1/server", summary: "获取服务器连接状态", tag: "server", response: message.ServiceInfo{}},
+	{method: "POST", path: "/api/v1/server/connect", summary: "连接服务器", tag: "server", request: message.ConnectServerMsg{}, response: message.ServiceInfo{}},
+	{method: "POST", path: "/api/v1/server/disconnect", summary: "断开服务器并解锁配置", tag: "server", response: message.ServiceInfo{}},
+	{method: "GET", path: "/api/v1/server/dashboard", summary: "通过 frps dashboard 获取服务器信息", tag: "server", response: message.DashboardInfo{}},
+	{method: "GET", path: "/api/v1/server/free-port", summary: "查找空闲的远程端口", tag: "server", query: []string{"type"}, response: message.FreePort{}},
+	{method: "GET", path: "/api/v1/events", summary: "事件推送（SSE）", tag: "events", response: message.Event{}, contentType: "text/event-stream", plain: true},
+	{method: "GET", path: "/api/v1/logs", summary: "查询最近的日志", tag: "logs", query: []string{"level", "proxy", "source", "since", "limit"}, response: message.LogEntries{}},
+	{method: "GET", path: "/api/v1/audit", summary: "查询或导出配置变更审计记录，format 为 csv、jsonl 时以附件下载", tag: "audit", query: []string{"action", "target", "source", "since", "until", "limit", "format"}, response: message.AuditRecords{}},
+	{method: "GET", path: "/api/v1/logs/tail", summary: "实时推送日志（SSE）", tag: "logs", query: []string{"level", "proxy", "source", "limit"}, response: message.Event{}, contentType: "text/event-stream", plain: true},
+	{method: "GET", path: "/api/v1/backup", summary: "导出备份", tag: "backup", contentType: "application/zip", plain: true},
+	{method: "POST", path: "/api/v1/restore", summary: "恢复备份", tag: "backup", query: []string{"mode", "dryRun"}, upload: "application/zip", response: message.RestoreReport{}},
+}
+
+// openapiHandler GET /api/openapi.json
+func openapiHandler(writer http.ResponseWriter, request *http.Request) {
+	data, _ := json.MarshalIndent(buildOpenAPI(), "", "  ")
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(data)
+}
+
+// runOpenAPIFlags 处理 -openapi 和 -openapi-check，处理后返回 true 表示需要退出
+func runOpenAPIFlags() bool {
+	if *openapiOutFlag == "" && *openapiCheckFlag == "" {
+		return false
+	}
+
+	data, err := openAPIDocument()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if *openapiCheckFlag != "" {
+		old, err := os.ReadFile(*openapiCheckFlag)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if string(old) != string(data) {
+			fmt.Fprintf(os.Stderr, "%s 与代码不一致，请执行 go run . -openapi %s 重新生成\n", *openapiCheckFlag, *openapiCheckFlag)
+			os.Exit(1)
+		}
+		return true
+	}
+
+	if *openapiOutFlag == "-" {
+		os.Stdout.Write(data)
+		return true
+	}
+	if err := os.WriteFile(*openapiOutFlag, data, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	return true
+}
+
+// openAPIDocument 校验路由后生成 frontend/openapi.json 的内容
+func openAPIDocument() ([]byte, error) {
+	if err := checkOpenAPIRoutes(getLocalServerRoute()); err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(buildOpenAPI(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// checkOpenAPIRoutes 校验路由注册与 apiRoutes 一一对应
+func checkOpenAPIRoutes(router *mux.Router) error {
+	documented := map[string]bool{}
+	for _, route := range apiRoutes {
+		documented[route.method+" "+route.path] = true
+	}
+
+	registered := map[string]bool{}
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range methods {
+			registered[method+" "+path] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for key := range registered {
+		if !documented[key] {
+			missing = append(missing, "未写入文档: "+key)
+		}
+	}
+	for key := range documented {
+		if !registered[key] {
+			missing = append(missing, "未注册路由: "+key)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("OpenAPI 文档与路由不一致:\n%s", strings.Join(missing, "\n"))
+	}
+	return nil
+}
+
+// buildOpenAPI 根据 apiRoutes 和 message 中的类型生成 OpenAPI 3 文档
+func buildOpenAPI() map[string]interface{} {
+	schemas := openapiSchemas{}
+	paths := map[string]map[string]interface{}{}
+
+	for _, route := range apiRoutes {
+		op := map[string]interface{}{
+			"summary":     route.summary,
+			"tags":        []string{route.tag},
+			"operationId": operationID(route),
+		}
+
+		list := make([]interface{}, 0)
+		for _, name := range pathParams(route.path) {
+			list = append(list, map[string]interface{}{
+				"name":     name,
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "string"},
+			})
+		}
+		for _, name := range route.query {
+			list = append(list, map[string]interface{}{
+				"name":   name,
+				"in":     "query",
+				"schema": map[string]interface{}{"type": "string"},
+			})
+		}
+		if len(list) > 0 {
+			op["parameters"] = list
+		}
+
+		if route.request != nil {
+			op["requestBody"] = map[string]interface{}{
+				"required": !route.optional,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": schemas.of(reflect.TypeOf(route.request)),
+					},
+				},
+			}
+		}
+		if route.upload != "" {
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					route.upload: map[string]interface{}{
+						"schema": map[string]interface{}{"type": "string", "format": "binary"},
+					},
+				},
+			}
+		}
+
+		status := route.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		responses := map[string]interface{}{
+			fmt.Sprint(status): routeResponse(route, schemas),
+		}
+		if !route.plain {
+			responses["default"] = map[string]interface{}{
+				"description": "失败，code 为机器可读错误码",
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": schemas.of(reflect.TypeOf(message.Response{})),
+					},
+				},
+			}
+		}
+		op["responses"] = responses
+
+		if paths[route.path] == nil {
+			paths[route.path] = map[string]interface{}{}
+		}
+		paths[route.path][strings.ToLower(route.method)] = op
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "frp-client local API",
+			"version": appVersion,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{
+					"type":   "http",
+					"scheme": "bearer",
+				},
+				"sessionCookie": map[string]interface{}{
+					"type": "apiKey",
+					"in":   "cookie",
+					"name": sessionCookieName,
+				},
+			},
+		},
+		"security": []interface{}{
+			map[string]interface{}{"bearerAuth": []string{}},
+			map[string]interface{}{"sessionCookie": []string{}},
+		},
+	}
+}
+
+func routeResponse(route apiRoute, schemas openapiSchemas) map[string]interface{} {
+	resp := map[string]interface{}{"description": route.summary}
+
+	if route.contentType != "" && route.contentType != "application/json" {
+		content := map[string]interface{}{}
+		if route.response != nil {
+			content["schema"] = schemas.of(reflect.TypeOf(route.response))
+		} else {
+			content["schema"] = map[string]interface{}{"type": "string", "format": "binary"}
+		}
+		resp["content"] = map[string]interface{}{route.contentType: content}
+		return resp
+	}
+	if route.contentType == "application/json" && route.response == nil {
+		resp["content"] = map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": map[string]interface{}{"type": "object"}},
+		}
+		return resp
+	}
+
+	var schema interface{}
+	if route.plain {
+		schema = schemas.of(reflect.TypeOf(route.response))
+	} else {
+		data := map[string]interface{}{"nullable": true}
+		if route.response != nil {
+			data = schemas.of(reflect.TypeOf(route.response))
+		}
+		schema = map[string]interface{}{
+			"allOf": []interface{}{
+				schemas.of(reflect.TypeOf(message.Response{})),
+				map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{"data": data},
+				},
+			},
+		}
+	}
+	resp["content"] = map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": schema},
+	}
+	return resp
+}
+
+// openapiSchemas components.schemas，按 Go 类型名注册
+type openapiSchemas map[string]interface{}
+
+// of 返回类型对应的 schema，具名结构体注册到 components 后返回引用
+func (s openapiSchemas) of(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := s.of(t.Elem())
+		if _, isRef := schema["$ref"]; isRef {
+			return map[string]interface{}{"allOf": []interface{}{schema}, "nullable": true}
+		}
+		schema["nullable"] = true
+		return schema
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Uint, reflect.Uint32, reflect.Int16, reflect.Uint16, reflect.Int8, reflect.Uint8:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": s.of(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": s.of(t.Elem())}
+	case reflect.Interface:
+		return map[string]interface{}{}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.structSchema(t)
+		}
+		ref := map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+		if _, has := s[t.Name()]; !has {
+			// 先占位，避免递归类型死循环
+			s[t.Name()] = map[string]interface{}{}
+			s[t.Name()] = s.structSchema(t)
+		}
+		return ref
+	}
+	return map[string]interface{}{}
+}
+
+// structSchema 根据 json tag 生成结构体 schema，匿名嵌入字段展开
+func (s openapiSchemas) structSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	s.collectFields(t, properties)
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+}
+
+func (s openapiSchemas) collectFields(t reflect.Type, properties map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			s.collectFields(field.Type, properties)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = s.of(field.Type)
+	}
+}
+
+// pathParams 提取路径中的 {param}
+func pathParams(path string) []string {
+	var params []string
+	for _, part := range strings.Split(path, "/") {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			params = append(params, strings.Trim(part, "{}"))
+		}
+	}
+	return params
+}
+
+// operationID 生成客户端方法名，如 GET /api/v1/proxies/{name} -> getV1ProxiesByName
+func operationID(route apiRoute) string {
+	id := strings.ToLower(route.method)
+	for _, part := range strings.Split(strings.TrimPrefix(route.path, "/api/"), "/") {
+		if part == "" {
+			continue
+		}
+		if strings.HasPrefix(part, "{") {
+			part = "by_" + strings.Trim(part, "{}")
+		}
+		part = strings.TrimSuffix(part, ".json")
+		for _, word := range strings.Split(part, "_") {
+			if word != "" {
+				id += strings.ToUpper(word[:1]) + word[1:]
+			}
+		}
+	}
+	return id
+}
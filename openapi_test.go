@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestOpenAPIUpToDate 提交的 OpenAPI 文档必须与路由和 message 类型一致
+func TestOpenAPIUpToDate(t *testing.T) {
+	data, err := openAPIDocument()
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, err := os.ReadFile("frontend/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(old) == string(data) {
+		return
+	}
+
+	want, got := strings.Split(string(data), "\n"), strings.Split(string(old), "\n")
+	for i := 0; i < len(want) || i < len(got); i++ {
+		var w, g string
+		if i < len(want) {
+			w = want[i]
+		}
+		if i < len(got) {
+			g = got[i]
+		}
+		if w != g {
+			t.Fatalf("frontend/openapi.json 第 %d 行为 %q，应为 %q，请执行 go generate . 重新生成", i+1, g, w)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+//go:build race
+
+package main
+
+// raceEnabled 是否使用 -race 运行测试
+const raceEnabled = true
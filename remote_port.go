@@ -0,0 +1,27 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/douguohai/frp-client/logger"
+	"github.com/fatedier/frp/pkg/util/util"
+)
+
+// defaultRemotePortRange 默认的空闲端口查找范围
+const defaultRemotePortRange = "10000-60000"
+
+var remotePortRangeFlag = flag.String("remote-port-range", defaultRemotePortRange, "查找空闲远程端口的范围，格式与 frps allow_ports 一致，如 2000-3000,3001,3003-4000")
+
+// remotePortRange 解析空闲端口查找范围，格式错误时使用默认范围
+func remotePortRange() []int {
+	numbers, err := util.ParseRangeNumbers(*remotePortRangeFlag)
+	if err != nil {
+		logger.Warn("远程端口范围格式错误，使用默认范围", "range", *remotePortRangeFlag, "default", defaultRemotePortRange, "err", err)
+		numbers, _ = util.ParseRangeNumbers(defaultRemotePortRange)
+	}
+	ports := make([]int, 0, len(numbers))
+	for _, n := range numbers {
+		ports = append(ports, int(n))
+	}
+	return ports
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/douguohai/frp-client/logger"
+	"github.com/douguohai/frp-client/message"
+)
+
+// AuditCollection 审计记录在存储中的 collection，只追加，不随备份迁移
+const AuditCollection = "audit"
+
+// auditSecretMask 审计记录中替换密钥类字段的值
+const auditSecretMask = "******"
+
+// SystemActor 程序自动执行的操作
+var SystemActor = message.AuditActor{Source: message.AuditSourceSystem}
+
+// AuditService 记录配置变更，记录按时间命名，写入后不再修改
+type AuditService struct {
+	store Store
+
+	mu   sync.Mutex
+	last int64
+}
+
+// NewAuditService 创建审计服务
+func NewAuditService(store Store) *AuditService {
+	return &AuditService{store: store}
+}
+
+// Record 追加一条审计记录，opErr 为操作失败原因，写入失败只记录日志，不影响操作本身
+// s 为 nil 时不记录
+func (s *AuditService) Record(actor message.AuditActor, action, target string, before, after interface{}, opErr error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 编号为纳秒时间，同一纳秒内的多条记录顺延，保证唯一且递增
+	now := time.Now().UnixNano()
+	if now <= s.last {
+		now = s.last + 1
+	}
+	s.last = now
+
+	record := message.AuditRecord{
+		ID:         fmt.Sprintf("%019d", now),
+		Time:       now,
+		Action:     action,
+		Target:     target,
+		AuditActor: actor,
+		Before:     before,
+		After:      after,
+	}
+	if opErr != nil {
+		record.Err = opErr.Error()
+	}
+	if err := s.store.Write(AuditCollection, record.ID, record); err != nil {
+		logger.Error("写入审计记录失败", "action", action, "target", target, "err", err)
+	}
+}
+
+// AuditFilter 审计记录查询条件，零值表示不限制
+type AuditFilter struct {
+	Action string // 操作
+	Target string // 操作对象
+	Source string // 来源
+	Since  int64  // 开始时间，包含
+	Until  int64  // 结束时间，不包含
+	Limit  int    // 最多返回最近的条数
+}
+
+func (f AuditFilter) match(r message.AuditRecord) bool {
+	switch {
+	case f.Action != "" && r.Action != f.Action,
+		f.Target != "" && r.Target != f.Target,
+		f.Source != "" && r.Source != f.Source,
+		f.Since != 0 && r.Time < f.Since,
+		f.Until != 0 && r.Time >= f.Until:
+		return false
+	}
+	return true
+}
+
+// Query 查询审计记录，按时间排序
+func (s *AuditService) Query(f AuditFilter) ([]message.AuditRecord, error) {
+	raw, err := s.store.ReadAll(AuditCollection)
+	if os.IsNotExist(err) {
+		// 没有任何记录时 collection 目录不存在
+		return []message.AuditRecord{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	records := []message.AuditRecord{}
+	for _, b := range raw {
+		r := message.AuditRecord{}
+		if err := json.Unmarshal(b, &r); err != nil {
+			logger.Warn("解析审计记录失败", "err", err)
+			continue
+		}
+		if f.match(r) {
+			records = append(records, r)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].ID < records[j].ID
+	})
+	if f.Limit > 0 && len(records) > f.Limit {
+		records = records[len(records)-f.Limit:]
+	}
+	return records, nil
+}
+
+// auditProxy 审计记录中的代理配置，去掉运行状态并隐藏负载均衡组密钥
+func auditProxy(p message.ProxyMsg) message.ProxyMsg {
+	p.RunStatus, p.RemoteAddr, p.Err, p.ErrReason = "", "", "", ""
+	p.LocalStatus, p.LocalErr = "", ""
+	p.HealthStatus, p.HealthCheckAt = "", 0
+	if p.GroupKey != "" {
+		p.GroupKey = auditSecretMask
+	}
+	return p
+}
+
+// auditServer 审计记录中的服务器配置，隐藏 dashboard 密码
+func auditServer(server message.ConnectServerMsg) message.ConnectServerMsg {
+	if server.DashboardPassword != "" {
+		server.DashboardPassword = auditSecretMask
+	}
+	return server
+}
@@ -0,0 +1,336 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/douguohai/frp-client/logger"
+	"github.com/douguohai/frp-client/message"
+	"github.com/fatedier/frp/client"
+	"github.com/fatedier/frp/pkg/config"
+)
+
+var (
+	ErrServerNotConfigured = errors.New("请配置服务器并锁定配置")
+	ErrConnectFailed       = errors.New("连接失败,请检查服务器配置信息")
+)
+
+// 连接状态，与 message.ServiceInfo.RunStatus 一致
+const (
+	RunStatusClosed     int64 = 0
+	RunStatusRunning    int64 = 1
+	RunStatusConnecting int64 = -1
+)
+
+// connectWait 连接后等待登录结果的时间，超时未返回错误视为连接成功
+var connectWait = 4 * time.Second
+
+// Publisher 事件推送
+type Publisher interface {
+	Publish(eventType string, body interface{})
+}
+
+// ConnectionService 管理与 frps 的连接
+type ConnectionService struct {
+	newClient ClientFactory
+	publisher Publisher
+
+	// Configure 连接前调整 frpc 配置，如 admin 接口
+	Configure func(cfg *config.ClientCommonConf)
+
+	// proxies 连接状态变化时关闭或重新加载代理
+	proxies *ProxyService
+
+	// Audit 记录连接和解锁，为 nil 时不记录
+	Audit *AuditService
+
+	mu         sync.RWMutex
+	frpc       FrpClient
+	serverIp   string
+	serverPort int
+	cfg        config.ClientCommonConf
+
+	dashboardURL      string
+	dashboardUser     string
+	dashboardPassword string
+	dashboard         *DashboardClient
+
+	// logins 创建连接服务时已有的 frpc 登录次数
+	logins int64
+
+	run int64
+}
+
+// NewConnectionService 创建连接服务
+func NewConnectionService(newClient ClientFactory, publisher Publisher) *ConnectionService {
+	return &ConnectionService{
+		newClient: newClient,
+		publisher: publisher,
+		cfg:       config.GetDefaultClientConf(),
+		logins:    FrpLogins(),
+	}
+}
+
+// Connect 设置服务器并连接，connectWait 内未返回错误视为连接成功
+// 连接失败时服务器设置已生效，同样记录审计
+func (s *ConnectionService) Connect(actor message.AuditActor, serverInfo message.ConnectServerMsg) error {
+	before := s.Server()
+	s.SetServer(serverInfo)
+	after := s.Server()
+	target := net.JoinHostPort(after.ServerIp, strconv.Itoa(after.ServerPort))
+
+	// 创建一个通道，带缓冲避免超时返回后连接失败时协程阻塞
+	ch := make(chan error, 1)
+
+	// 启动一个协程执行某个任务，并将通道传递给它
+	go s.connect(ch)
+
+	// 等待协程的反馈消息，超时视为成功
+	select {
+	case err := <-ch:
+		logger.Error("连接服务器失败", "server", target, "err", err)
+		s.Audit.Record(actor, message.AuditActionConnect, target, auditServer(before), auditServer(after), err)
+		return ErrConnectFailed
+	case <-time.After(connectWait):
+		logger.Info("连接未返回错误，默认认为启动成功", "server", target)
+	}
+	s.Audit.Record(actor, message.AuditActionConnect, target, auditServer(before), auditServer(after), nil)
+	return nil
+}
+
+// connect 连接frp服务器
+func (s *ConnectionService) connect(ch chan error) {
+	if s.Running() {
+		s.proxies.CloseAll()
+		s.client().Close()
+	}
+	atomic.StoreInt64(&s.run, RunStatusConnecting)
+	s.publishConnection()
+
+	s.mu.Lock()
+	s.cfg.DialServerTimeout = 3
+	if s.Configure != nil {
+		s.Configure(&s.cfg)
+	}
+	s.cfg.ServerAddr = s.serverIp
+	s.cfg.ServerPort = s.serverPort
+	cfg := s.cfg
+	s.mu.Unlock()
+
+	if err := cfg.Validate(); err != nil {
+		logger.Error("frpc 配置校验失败", "err", err)
+		atomic.CompareAndSwapInt64(&s.run, RunStatusConnecting, RunStatusClosed)
+		s.publishError("connect", "", err.Error())
+		s.publishConnection()
+		ch <- err
+		return
+	}
+
+	frpc, err := s.newClient(cfg)
+	if err != nil {
+		atomic.CompareAndSwapInt64(&s.run, RunStatusConnecting, RunStatusClosed)
+		s.publishError("connect", "", err.Error())
+		s.publishConnection()
+		ch <- err
+		return
+	}
+	s.mu.Lock()
+	s.frpc = frpc
+	s.mu.Unlock()
+
+	if atomic.CompareAndSwapInt64(&s.run, RunStatusConnecting, RunStatusRunning) {
+		s.publishConnection()
+	}
+	if err := frpc.Run(context.Background()); err != nil {
+		ch <- err
+		atomic.CompareAndSwapInt64(&s.run, RunStatusRunning, RunStatusClosed)
+		s.publishError("connect", "", err.Error())
+		s.publishConnection()
+		s.proxies.CloseAll()
+		s.proxies.Reload()
+	}
+}
+
+// Disconnect 断开服务器并解锁配置
+func (s *ConnectionService) Disconnect(actor message.AuditActor) error {
+	if !s.Configured() {
+		return ErrServerNotConfigured
+	}
+	server := s.Server()
+	s.unlock()
+	logger.Info("断开服务器并解锁配置")
+	s.Audit.Record(actor, message.AuditActionUnlock, net.JoinHostPort(server.ServerIp, strconv.Itoa(server.ServerPort)), auditServer(server), nil, nil)
+	return nil
+}
+
+// Close 退出时关闭全部代理并断开连接
+func (s *ConnectionService) Close() {
+	s.unlock()
+}
+
+// unlock 解锁frp服务器配置
+func (s *ConnectionService) unlock() {
+	s.mu.Lock()
+	s.cfg.ServerAddr = ""
+	s.cfg.ServerPort = 0
+	s.mu.Unlock()
+
+	s.proxies.CloseAll()
+	s.proxies.Reload()
+	if s.Running() {
+		s.client().Close()
+		atomic.CompareAndSwapInt64(&s.run, RunStatusRunning, RunStatusClosed)
+	} else {
+		atomic.CompareAndSwapInt64(&s.run, RunStatusConnecting, RunStatusClosed)
+	}
+	s.publishConnection()
+}
+
+// SetServer 设置服务器地址，下次连接时生效
+func (s *ConnectionService) SetServer(serverInfo message.ConnectServerMsg) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.serverIp = serverInfo.ServerIp
+	s.serverPort = serverInfo.ServerPort
+
+	password := serverInfo.DashboardPassword
+	if password == "" && serverInfo.DashboardUser == s.dashboardUser {
+		// 界面不回显密码，用户名不变时留空表示保持原密码
+		password = s.dashboardPassword
+	}
+	s.dashboardURL = strings.TrimSpace(serverInfo.DashboardURL)
+	s.dashboardUser = serverInfo.DashboardUser
+	s.dashboardPassword = password
+	s.dashboard = nil
+	if s.dashboardURL != "" {
+		s.dashboard = NewDashboardClient(s.dashboardURL, s.dashboardUser, s.dashboardPassword)
+	}
+}
+
+// ChangeServer 设置服务器并记录审计，下次连接时生效
+func (s *ConnectionService) ChangeServer(actor message.AuditActor, serverInfo message.ConnectServerMsg) {
+	before := s.Server()
+	s.SetServer(serverInfo)
+	after := s.Server()
+	s.Audit.Record(actor, message.AuditActionSetServer, net.JoinHostPort(after.ServerIp, strconv.Itoa(after.ServerPort)), auditServer(before), auditServer(after), nil)
+}
+
+// Dashboard frps dashboard 客户端，未配置时返回 nil
+func (s *ConnectionService) Dashboard() *DashboardClient {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.dashboard
+}
+
+// Server 当前设置的服务器地址
+func (s *ConnectionService) Server() message.ConnectServerMsg {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return message.ConnectServerMsg{
+		ServerIp:          s.serverIp,
+		ServerPort:        s.serverPort,
+		DashboardURL:      s.dashboardURL,
+		DashboardUser:     s.dashboardUser,
+		DashboardPassword: s.dashboardPassword,
+	}
+}
+
+// Configured 是否已设置服务器
+func (s *ConnectionService) Configured() bool {
+	server := s.Server()
+	return server.ServerIp != "" && server.ServerPort != 0
+}
+
+// ServerAddr 已连接的服务器地址
+func (s *ConnectionService) ServerAddr() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg.ServerAddr
+}
+
+// RunStatus 连接状态
+func (s *ConnectionService) RunStatus() int64 {
+	return atomic.LoadInt64(&s.run)
+}
+
+// Running 是否已连接
+func (s *ConnectionService) Running() bool {
+	return s.RunStatus() == RunStatusRunning
+}
+
+// Info 服务器连接信息
+func (s *ConnectionService) Info() message.ServiceInfo {
+	if !s.Configured() {
+		return message.ServiceInfo{
+			ServerIp:   "127.0.0.1",
+			ServerPort: 0,
+			RunStatus:  RunStatusClosed,
+			Time:       time.Now().UnixNano(),
+		}
+	}
+	server := s.Server()
+	return message.ServiceInfo{
+		ServerIp:      server.ServerIp,
+		ServerPort:    server.ServerPort,
+		RunStatus:     s.RunStatus(),
+		Time:          time.Now().UnixNano(),
+		DashboardURL:  server.DashboardURL,
+		DashboardUser: server.DashboardUser,
+	}
+}
+
+// Reload 重新加载代理配置，未连接时忽略
+func (s *ConnectionService) Reload(pxyCfgs map[string]config.ProxyConf) error {
+	if !s.Running() {
+		return nil
+	}
+	if err := s.client().ReloadConf(pxyCfgs, nil); err != nil {
+		logger.Error("加载代理配置失败", "err", err)
+		s.publishError("reload", "", err.Error())
+		return err
+	}
+	return nil
+}
+
+// ProxyStatus 代理运行状态，未连接时为空
+func (s *ConnectionService) ProxyStatus() (map[string]client.ProxyStatusResp, error) {
+	if !s.Running() {
+		return map[string]client.ProxyStatusResp{}, nil
+	}
+	return s.client().ProxyStatus()
+}
+
+// Reconnects 启动以来的重新登录次数，包括手动重新连接和断线后 frpc 的自动重连
+func (s *ConnectionService) Reconnects() int64 {
+	logins := FrpLogins() - s.logins
+	if logins <= 1 {
+		return 0
+	}
+	return logins - 1
+}
+
+func (s *ConnectionService) client() FrpClient {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.frpc
+}
+
+// publishConnection 推送当前服务器连接状态
+func (s *ConnectionService) publishConnection() {
+	s.publisher.Publish(message.EventConnection, s.Info())
+}
+
+// publishError 推送错误事件
+func (s *ConnectionService) publishError(source, proxyName, msg string) {
+	s.publisher.Publish(message.EventError, message.ErrorEvent{
+		Source:    source,
+		ProxyName: proxyName,
+		Msg:       msg,
+	})
+}
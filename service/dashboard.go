@@ -0,0 +1,199 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/douguohai/frp-client/logger"
+	"github.com/douguohai/frp-client/message"
+	"github.com/fatedier/frp/pkg/consts"
+)
+
+var (
+	ErrDashboardNotConfigured = errors.New("未配置 frps dashboard")
+	ErrDashboardFailed        = errors.New("访问 frps dashboard 失败")
+)
+
+const (
+	// dashboardTimeout 单次请求 dashboard 的超时时间
+	dashboardTimeout = 3 * time.Second
+	// dashboardCacheTTL 代理列表刷新频繁，dashboard 数据缓存的时间
+	dashboardCacheTTL = 5 * time.Second
+)
+
+// DashboardClient frps dashboard 接口客户端，使用 basic auth 认证
+type DashboardClient struct {
+	baseURL  string
+	user     string
+	password string
+	client   *http.Client
+}
+
+// NewDashboardClient 创建 dashboard 客户端，地址未带协议时按 http 处理
+func NewDashboardClient(baseURL, user, password string) *DashboardClient {
+	baseURL = strings.TrimRight(strings.TrimSpace(baseURL), "/")
+	if baseURL != "" && !strings.Contains(baseURL, "://") {
+		baseURL = "http://" + baseURL
+	}
+	return &DashboardClient{
+		baseURL:  baseURL,
+		user:     user,
+		password: password,
+		client:   &http.Client{Timeout: dashboardTimeout},
+	}
+}
+
+// DashboardProxy frps 上的代理信息，对应 /api/proxy/{type} 的返回
+type DashboardProxy struct {
+	Name            string `json:"name"`
+	TodayTrafficIn  int64  `json:"today_traffic_in"`
+	TodayTrafficOut int64  `json:"today_traffic_out"`
+	CurConns        int64  `json:"cur_conns"`
+	LastStartTime   string `json:"last_start_time"`
+	LastCloseTime   string `json:"last_close_time"`
+	Status          string `json:"status"`
+}
+
+// ServerInfo 获取 frps 版本、在线客户端数和今日流量
+func (c *DashboardClient) ServerInfo() (message.DashboardInfo, error) {
+	var resp struct {
+		Version         string           `json:"version"`
+		ClientCounts    int64            `json:"client_counts"`
+		CurConns        int64            `json:"cur_conns"`
+		TotalTrafficIn  int64            `json:"total_traffic_in"`
+		TotalTrafficOut int64            `json:"total_traffic_out"`
+		ProxyTypeCounts map[string]int64 `json:"proxy_type_count"`
+	}
+	if err := c.get("/api/serverinfo", &resp); err != nil {
+		return message.DashboardInfo{}, err
+	}
+	return message.DashboardInfo{
+		Version:         resp.Version,
+		ClientCounts:    resp.ClientCounts,
+		CurConns:        resp.CurConns,
+		TotalTrafficIn:  resp.TotalTrafficIn,
+		TotalTrafficOut: resp.TotalTrafficOut,
+		ProxyTypeCounts: resp.ProxyTypeCounts,
+	}, nil
+}
+
+// Proxies 获取 frps 上指定类型的全部代理，包括其他客户端的代理
+func (c *DashboardClient) Proxies(proxyType string) ([]DashboardProxy, error) {
+	var resp struct {
+		Proxies []DashboardProxy `json:"proxies"`
+	}
+	if err := c.get("/api/proxy/"+proxyType, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Proxies, nil
+}
+
+func (c *DashboardClient) get(path string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDashboardFailed, err)
+	}
+	if c.user != "" || c.password != "" {
+		req.SetBasicAuth(c.user, c.password)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDashboardFailed, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return fmt.Errorf("%w: 用户名或密码错误", ErrDashboardFailed)
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("%w: 状态码 %d", ErrDashboardFailed, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%w: %v", ErrDashboardFailed, err)
+	}
+	return nil
+}
+
+// DashboardInfo 通过 dashboard 获取 frps 服务器信息
+func (s *ConnectionService) DashboardInfo() (message.DashboardInfo, error) {
+	dashboard := s.Dashboard()
+	if dashboard == nil {
+		return message.DashboardInfo{}, ErrDashboardNotConfigured
+	}
+	return dashboard.ServerInfo()
+}
+
+// dashboardCache frps 上的代理信息，按远程代理名称索引
+type dashboardCache struct {
+	client     *DashboardClient
+	at         time.Time
+	refreshing bool
+	proxies    map[string]DashboardProxy
+	err        string
+}
+
+// serverProxies 获取 frps 上的代理信息，不等待 dashboard 响应
+// 缓存超过 dashboardCacheTTL 时在后台刷新，未配置 dashboard 或请求失败时返回空
+func (s *ProxyService) serverProxies() map[string]DashboardProxy {
+	dashboard := s.conn.Dashboard()
+	if dashboard == nil {
+		return nil
+	}
+
+	s.dashMu.Lock()
+	defer s.dashMu.Unlock()
+	cache := &s.dashCache
+	if cache.client != dashboard {
+		*cache = dashboardCache{client: dashboard}
+	}
+	if !cache.refreshing && time.Since(cache.at) >= dashboardCacheTTL {
+		cache.refreshing = true
+		go s.refreshServerProxies(dashboard)
+	}
+	return cache.proxies
+}
+
+// refreshServerProxies 请求 frps 上的 tcp、udp 代理并更新缓存，失败原因变化时记录日志
+func (s *ProxyService) refreshServerProxies(dashboard *DashboardClient) {
+	proxies := map[string]DashboardProxy{}
+	var err error
+	for _, proxyType := range []string{consts.TCPProxy, consts.UDPProxy} {
+		var list []DashboardProxy
+		if list, err = dashboard.Proxies(proxyType); err != nil {
+			break
+		}
+		for _, p := range list {
+			proxies[p.Name] = p
+		}
+	}
+
+	s.dashMu.Lock()
+	defer s.dashMu.Unlock()
+	cache := &s.dashCache
+	if cache.client != dashboard {
+		return
+	}
+	msg := ""
+	if err != nil {
+		msg = err.Error()
+		proxies = nil
+	}
+	if msg != "" && msg != cache.err {
+		logger.Warn("获取 frps dashboard 代理信息失败", "err", msg)
+	}
+	*cache = dashboardCache{client: dashboard, at: time.Now(), proxies: proxies, err: msg}
+}
+
+// applyServerProxy 把 frps 上的代理信息合并到展示信息
+func applyServerProxy(vo *message.ProxyMsgVo, p DashboardProxy) {
+	vo.ServerStatus = p.Status
+	vo.TodayTrafficIn = p.TodayTrafficIn
+	vo.TodayTrafficOut = p.TodayTrafficOut
+	vo.ServerConns = p.CurConns
+	vo.LastStartTime = p.LastStartTime
+	vo.LastCloseTime = p.LastCloseTime
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/douguohai/frp-client/message"
+)
+
+const (
+	testDashboardUser     = "admin"
+	testDashboardPassword = "secret"
+)
+
+// fakeDashboard 模拟 frps dashboard 接口，failing 不为 0 时全部返回 500
+type fakeDashboard struct {
+	*httptest.Server
+	requests int32
+	failing  int32
+}
+
+func newFakeDashboard(t *testing.T) *fakeDashboard {
+	t.Helper()
+	d := &fakeDashboard{}
+	d.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&d.requests, 1)
+		if user, password, ok := r.BasicAuth(); !ok || user != testDashboardUser || password != testDashboardPassword {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if atomic.LoadInt32(&d.failing) != 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/serverinfo":
+			w.Write([]byte(`{"version":"0.51.3","client_counts":2,"cur_conns":3,"total_traffic_in":100,"total_traffic_out":200,"proxy_type_count":{"tcp":4}}`))
+		case "/api/proxy/tcp":
+			w.Write([]byte(`{"proxies":[{"name":"web_1","status":"online","today_traffic_in":10,"cur_conns":1,"last_start_time":"01-01 09:00:00"}]}`))
+		case "/api/proxy/udp":
+			w.Write([]byte(`{"proxies":[{"name":"dns_1","status":"offline"}]}`))
+		case "/api/proxy/broken":
+			w.Write([]byte(`{"proxies":[`))
+		case "/api/proxy/slow":
+			time.Sleep(time.Second)
+			w.Write([]byte(`{"proxies":[]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(d.Close)
+	return d
+}
+
+func TestDashboardServerInfo(t *testing.T) {
+	d := newFakeDashboard(t)
+	// 地址未带协议时按 http 处理
+	c := NewDashboardClient(strings.TrimPrefix(d.URL, "http://")+"/", testDashboardUser, testDashboardPassword)
+	info, err := c.ServerInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := message.DashboardInfo{
+		Version:         "0.51.3",
+		ClientCounts:    2,
+		CurConns:        3,
+		TotalTrafficIn:  100,
+		TotalTrafficOut: 200,
+		ProxyTypeCounts: map[string]int64{"tcp": 4},
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Fatalf("服务器信息为 %+v", info)
+	}
+
+	proxies, err := c.Proxies("tcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(proxies) != 1 || proxies[0] != (DashboardProxy{Name: "web_1", Status: "online", TodayTrafficIn: 10, CurConns: 1, LastStartTime: "01-01 09:00:00"}) {
+		t.Fatalf("代理信息为 %+v", proxies)
+	}
+}
+
+func TestDashboardErrors(t *testing.T) {
+	d := newFakeDashboard(t)
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	cases := []struct {
+		name      string
+		url       string
+		password  string
+		proxyType string
+		msg       string
+	}{
+		{"密码错误", d.URL, "wrong", "tcp", "用户名或密码错误"},
+		{"未填写账号", d.URL, "", "tcp", "用户名或密码错误"},
+		{"状态码错误", d.URL, testDashboardPassword, "http", "状态码 404"},
+		{"返回格式错误", d.URL, testDashboardPassword, "broken", "unexpected EOF"},
+		{"超时", d.URL, testDashboardPassword, "slow", "Timeout"},
+		{"无法连接", closed.URL, testDashboardPassword, "tcp", "refused"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			user := testDashboardUser
+			if c.password == "" {
+				user = ""
+			}
+			client := NewDashboardClient(c.url, user, c.password)
+			client.client.Timeout = 100 * time.Millisecond
+			_, err := client.Proxies(c.proxyType)
+			if !errors.Is(err, ErrDashboardFailed) || !strings.Contains(err.Error(), c.msg) {
+				t.Fatalf("返回 %v，期望包含 %q", err, c.msg)
+			}
+		})
+	}
+}
+
+// TestServerProxiesCache 代理列表不等待 dashboard，缓存超过 dashboardCacheTTL 时在后台刷新
+func TestServerProxiesCache(t *testing.T) {
+	d := newFakeDashboard(t)
+	s := newTestProxyService(t)
+	if s.serverProxies() != nil {
+		t.Fatal("未配置 dashboard 时返回了代理信息")
+	}
+	s.conn.SetServer(message.ConnectServerMsg{
+		ServerIp:          "127.0.0.1",
+		ServerPort:        7000,
+		DashboardURL:      d.URL,
+		DashboardUser:     testDashboardUser,
+		DashboardPassword: testDashboardPassword,
+	})
+
+	// waitRefresh 等待后台刷新完成，返回刷新后的缓存
+	waitRefresh := func() dashboardCache {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			s.dashMu.Lock()
+			cache := s.dashCache
+			s.dashMu.Unlock()
+			if !cache.refreshing && !cache.at.IsZero() {
+				return cache
+			}
+		}
+		t.Fatal("dashboard 缓存未刷新")
+		return dashboardCache{}
+	}
+	// expire 缓存过期
+	expire := func() {
+		s.dashMu.Lock()
+		s.dashCache.at = time.Now().Add(-dashboardCacheTTL)
+		s.dashMu.Unlock()
+	}
+
+	if s.serverProxies() != nil {
+		t.Fatal("首次获取时等待了 dashboard")
+	}
+	waitRefresh()
+	proxies := s.serverProxies()
+	if len(proxies) != 2 || proxies["web_1"].Status != "online" || proxies["dns_1"].Status != "offline" {
+		t.Fatalf("代理信息为 %+v", proxies)
+	}
+	if n := atomic.LoadInt32(&d.requests); n != 2 {
+		t.Fatalf("请求了 %d 次 dashboard，期望 tcp、udp 各一次", n)
+	}
+
+	// 缓存有效期内不再请求
+	s.serverProxies()
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt32(&d.requests); n != 2 {
+		t.Fatalf("缓存有效期内请求了 dashboard，共 %d 次", n)
+	}
+
+	// 过期后返回旧数据并在后台刷新，失败时清空
+	atomic.StoreInt32(&d.failing, 1)
+	expire()
+	if len(s.serverProxies()) != 2 {
+		t.Fatal("刷新期间未返回旧数据")
+	}
+	cache := waitRefresh()
+	if cache.proxies != nil || !strings.Contains(cache.err, "状态码 500") {
+		t.Fatalf("刷新失败后缓存为 %+v", cache)
+	}
+	if n := atomic.LoadInt32(&d.requests); n != 3 {
+		t.Fatalf("tcp 失败后仍请求了 udp，共 %d 次", n)
+	}
+
+	atomic.StoreInt32(&d.failing, 0)
+	expire()
+	s.serverProxies()
+	if cache = waitRefresh(); len(cache.proxies) != 2 || cache.err != "" {
+		t.Fatalf("恢复后缓存为 %+v", cache)
+	}
+
+	// 修改 dashboard 设置后丢弃旧缓存
+	s.conn.SetServer(message.ConnectServerMsg{DashboardURL: d.URL, DashboardUser: testDashboardUser, DashboardPassword: "wrong"})
+	if s.serverProxies() != nil {
+		t.Fatal("修改设置后返回了旧缓存")
+	}
+	if cache = waitRefresh(); cache.proxies != nil || !strings.Contains(cache.err, "用户名或密码错误") {
+		t.Fatalf("密码错误时缓存为 %+v", cache)
+	}
+}
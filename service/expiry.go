@@ -0,0 +1,50 @@
+package service
+
+import (
+	"time"
+
+	"github.com/douguohai/frp-client/logger"
+	"github.com/douguohai/frp-client/message"
+)
+
+// ExpireWarning 到期前多久推送即将到期事件
+const ExpireWarning = 5 * time.Minute
+
+// ExpireActor 到期自动关闭、删除的操作来源
+var ExpireActor = message.AuditActor{Source: message.AuditSourceSystem, Client: "expire"}
+
+// expire 代理到期，按到期操作删除或关闭，关闭时取消到期时间
+func (s *ProxyService) expire(p message.ProxyMsg) {
+	logger.Info("代理到期", "proxy", p.ProxyName, "action", expireAction(p))
+	var err error
+	if p.ExpireAction == message.ExpireActionDelete {
+		err = s.Delete(ExpireActor, p.ProxyName)
+	} else {
+		_, err = s.setEnabled(ExpireActor, p.ProxyName, false, func(p *message.ProxyMsg) error {
+			p.ExpireAt = 0
+			return nil
+		})
+	}
+	if err != nil {
+		logger.Warn("代理到期处理失败", "proxy", p.ProxyName, "err", err)
+	}
+}
+
+// publishExpiring 推送代理即将到期事件
+func (s *ProxyService) publishExpiring(p message.ProxyMsg, now time.Time) {
+	logger.Warn("代理即将到期", "proxy", p.ProxyName, "action", expireAction(p), "expireAt", time.Unix(0, p.ExpireAt).Format(time.RFC3339))
+	s.publisher.Publish(message.EventExpiring, message.ProxyExpiringEvent{
+		ProxyName:    p.ProxyName,
+		ExpireAt:     p.ExpireAt,
+		ExpireAction: expireAction(p),
+		ExpiresInS:   expiresInS(p.ExpireAt, now),
+	})
+}
+
+// expireAction 到期操作，空为关闭
+func expireAction(p message.ProxyMsg) string {
+	if p.ExpireAction == "" {
+		return message.ExpireActionDisable
+	}
+	return p.ExpireAction
+}
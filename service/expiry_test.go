@@ -0,0 +1,101 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/douguohai/frp-client/message"
+)
+
+func TestCheckExpireAt(t *testing.T) {
+	now := time.Now().UnixNano()
+	past, future := now-int64(time.Minute), now+int64(time.Hour)
+	cases := []struct {
+		name     string
+		proxy    message.ProxyMsg
+		previous int64
+		ok       bool
+	}{
+		{"未设置", message.ProxyMsg{}, 0, true},
+		{"未来", message.ProxyMsg{ExpireAt: future}, 0, true},
+		{"已过", message.ProxyMsg{ExpireAt: past}, 0, false},
+		{"未修改的已过时间", message.ProxyMsg{ExpireAt: past}, past, true},
+		{"按有效时长重新计算", message.ProxyMsg{ExpireAt: past, ExpireIn: "1h"}, 0, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := checkExpireAt(c.proxy, c.previous)
+			if (err == nil) != c.ok {
+				t.Fatalf("返回 %v", err)
+			}
+			if err != nil && !errors.Is(err, ErrProxyConf) {
+				t.Fatalf("错误类型为 %T", err)
+			}
+		})
+	}
+}
+
+// TestExpiresInS 剩余秒数向上取整，未设置或已到期为 0
+func TestExpiresInS(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name     string
+		expireAt int64
+		want     int64
+	}{
+		{"未设置", 0, 0},
+		{"已到期", now.Add(-time.Second).UnixNano(), 0},
+		{"正好到期", now.UnixNano(), 0},
+		{"不足一秒", now.Add(time.Nanosecond).UnixNano(), 1},
+		{"整秒", now.Add(time.Minute).UnixNano(), 60},
+		{"向上取整", now.Add(time.Minute + 1500*time.Millisecond).UnixNano(), 62},
+	}
+	for _, c := range cases {
+		if got := expiresInS(c.expireAt, now); got != c.want {
+			t.Errorf("%s: %d，期望 %d", c.name, got, c.want)
+		}
+	}
+}
+
+func TestResolveExpireIn(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	proxy := message.ProxyMsg{ExpireIn: "90m", ExpireAt: 1}
+	resolveExpireIn(&proxy, now)
+	if proxy.ExpireIn != "" || proxy.ExpireAt != now.Add(90*time.Minute).UnixNano() {
+		t.Fatalf("换算后为 %q %d", proxy.ExpireIn, proxy.ExpireAt)
+	}
+	proxy = message.ProxyMsg{ExpireAt: 1}
+	resolveExpireIn(&proxy, now)
+	if proxy.ExpireAt != 1 {
+		t.Fatalf("未设置有效时长时到期时间变为 %d", proxy.ExpireAt)
+	}
+}
+
+// TestDisableCancelsExpiry 手动关闭后取消到期时间，到期操作为删除的代理同样取消
+func TestDisableCancelsExpiry(t *testing.T) {
+	s := newTestProxyService(t)
+	NewScheduler(s)
+	for _, action := range []string{message.ExpireActionDisable, message.ExpireActionDelete} {
+		name := "expire-" + action
+		addTestProxy(t, s, message.ProxyMsg{ProxyName: name, ExpireIn: "10m", ExpireAction: action})
+		vo, err := s.SetEnabled(SystemActor, name, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if vo.ExpireAt == 0 {
+			t.Fatalf("%s: 开启后到期时间被取消", action)
+		}
+		if vo, err = s.SetEnabled(SystemActor, name, false); err != nil {
+			t.Fatal(err)
+		}
+		if vo.ExpireAt != 0 || vo.ExpiresInS != 0 {
+			t.Fatalf("%s: 关闭后到期时间为 %d", action, vo.ExpireAt)
+		}
+
+		s.scheduler.Evaluate(time.Now().Add(time.Hour))
+		if len(s.Records(name)) != 1 {
+			t.Fatalf("%s: 关闭后仍按原到期时间删除", action)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/douguohai/frp-client/logger"
+	"github.com/douguohai/frp-client/message"
+	"github.com/fatedier/beego/logs"
+	frplog "github.com/fatedier/frp/pkg/util/log"
+)
+
+// frpLogAdapter frpc 日志输出的名称
+const frpLogAdapter = "frp-client"
+
+// frpLoginMsg frpc 每次登录成功时输出的日志，包括断线后的自动重新登录
+const frpLoginMsg = "login to server success"
+
+// frpStatusRequest 读取代理状态时 frpc admin 接口输出的日志，每次状态同步都会输出，不转入 logger
+const frpStatusRequest = "[/api/status]"
+
+// frpLogins frpc 登录成功的次数
+var frpLogins int64
+
+// FrpLogins frpc 登录成功的次数，在 frpc 的登录流程中按登录日志计数，需先调用 CaptureFrpLog
+func FrpLogins() int64 {
+	return atomic.LoadInt64(&frpLogins)
+}
+
+func init() {
+	logs.Register(frpLogAdapter, func() logs.Logger {
+		return frpLogWriter{}
+	})
+}
+
+// CaptureFrpLog 将 frpc 的日志转入 logger，替换 frpc 默认的控制台输出
+// frpc 至少输出 info 级别，用于统计登录次数，低于 level 的日志由 logger 丢弃
+func CaptureFrpLog(level logger.Level) error {
+	if err := frplog.Log.SetLogger(frpLogAdapter); err != nil {
+		return err
+	}
+	if level > logger.LevelInfo {
+		level = logger.LevelInfo
+	}
+	frplog.SetLogLevel(level.String())
+	return nil
+}
+
+// frpLogWriter 解析 frpc 的日志格式 "[I] [service.go:299] [runID] [代理名称] 内容"
+type frpLogWriter struct{}
+
+func (frpLogWriter) Init(config string) error {
+	return nil
+}
+
+func (frpLogWriter) WriteMsg(when time.Time, msg string, level int) error {
+	var kv []interface{}
+	// 级别前缀由 level 表示
+	if len(msg) >= 4 && msg[0] == '[' && msg[2] == ']' && msg[3] == ' ' {
+		msg = msg[4:]
+	}
+
+	var prefixes []string
+	for strings.HasPrefix(msg, "[") {
+		end := strings.Index(msg, "] ")
+		if end < 0 {
+			break
+		}
+		prefix := msg[1:end]
+		msg = msg[end+2:]
+		switch name, ok := localProxyName(prefix); {
+		case ok:
+			kv = append(kv, "proxy", name)
+		case strings.HasSuffix(prefix, ".go") || strings.Contains(prefix, ".go:"):
+			kv = append(kv, "caller", prefix)
+		default:
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	if len(prefixes) > 0 {
+		kv = append(kv, "prefix", strings.Join(prefixes, " "))
+	}
+
+	if strings.HasPrefix(msg, frpLoginMsg) {
+		atomic.AddInt64(&frpLogins, 1)
+	}
+	if strings.HasPrefix(msg, "Http ") && strings.HasSuffix(msg, frpStatusRequest) {
+		return nil
+	}
+	logger.Log(frpLogLevel(level), message.LogSourceFrp, msg, kv...)
+	return nil
+}
+
+func (frpLogWriter) Destroy() {}
+
+func (frpLogWriter) Flush() {}
+
+// frpLogLevel beego 日志级别对应的级别
+func frpLogLevel(level int) logger.Level {
+	switch {
+	case level <= logs.LevelError:
+		return logger.LevelError
+	case level == logs.LevelWarn:
+		return logger.LevelWarn
+	case level <= logs.LevelInfo:
+		return logger.LevelInfo
+	default:
+		return logger.LevelDebug
+	}
+}
+
+// localProxyName 远程代理名称为 "代理名称_新增时间"，还原出代理名称
+func localProxyName(remote string) (string, bool) {
+	i := strings.LastIndex(remote, "_")
+	if i <= 0 {
+		return "", false
+	}
+	if _, err := strconv.ParseInt(remote[i+1:], 10, 64); err != nil {
+		return "", false
+	}
+	return remote[:i], true
+}
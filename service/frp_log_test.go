@@ -0,0 +1,37 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/douguohai/frp-client/logger"
+	"github.com/fatedier/beego/logs"
+)
+
+// 登录日志计入登录次数，admin 接口读取状态的日志不转入 logger
+func TestFrpLogWriter(t *testing.T) {
+	ch := logger.Subscribe()
+	defer logger.Unsubscribe(ch)
+
+	w := frpLogWriter{}
+	before := FrpLogins()
+	for _, msg := range []string{
+		"[I] [service.go:301] [8a2c] login to server success, get run id [8a2c], server udp port [0]",
+		"[I] [admin_api.go:154] Http request [/api/status]",
+		"[I] [admin_api.go:156] Http response [/api/status]",
+		"[I] [service.go:213] [8a2c] try to reconnect to server...",
+	} {
+		w.WriteMsg(time.Now(), msg, logs.LevelInfo)
+	}
+	if got := FrpLogins() - before; got != 1 {
+		t.Fatalf("登录次数增加了 %d", got)
+	}
+
+	var got []string
+	for len(ch) > 0 {
+		got = append(got, (<-ch).Msg)
+	}
+	if len(got) != 2 || got[0] != "login to server success, get run id [8a2c], server udp port [0]" || got[1] != "try to reconnect to server..." {
+		t.Fatalf("转入 logger 的日志为 %q", got)
+	}
+}
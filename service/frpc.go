@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/douguohai/frp-client/utils"
+	"github.com/fatedier/frp/client"
+	"github.com/fatedier/frp/pkg/config"
+)
+
+// FrpClient frpc 客户端
+type FrpClient interface {
+	// Run 登录服务器并阻塞运行，登录失败时返回错误
+	Run(ctx context.Context) error
+	ReloadConf(pxyCfgs map[string]config.ProxyConf, visitorCfgs map[string]config.VisitorConf) error
+	Close()
+	// ProxyStatus 全部代理的运行状态，key 为远程代理名称，读取失败时返回错误
+	ProxyStatus() (map[string]client.ProxyStatusResp, error)
+}
+
+// ClientFactory 根据配置创建 frpc 客户端
+type ClientFactory func(cfg config.ClientCommonConf) (FrpClient, error)
+
+// adminTimeout 请求 frpc admin 接口的超时时间
+const adminTimeout = 2 * time.Second
+
+// NewFrpClient 创建真实的 frpc 客户端
+// 代理状态通过 frpc admin 接口读取：未设置端口时监听本机随机端口，未设置凭据时随机生成
+// frp 关闭客户端时不会关闭 admin 监听，每个客户端使用各自的端口，避免读取到旧客户端的状态
+func NewFrpClient(cfg config.ClientCommonConf) (FrpClient, error) {
+	if cfg.AdminPort == 0 {
+		port, err := utils.GetAvailablePort()
+		if err != nil {
+			return nil, err
+		}
+		cfg.AdminAddr = "127.0.0.1"
+		cfg.AdminPort = port
+	}
+	if cfg.AdminUser == "" || cfg.AdminPwd == "" {
+		cfg.AdminUser = "frpc-" + utils.RandomHex(4)
+		cfg.AdminPwd = utils.RandomHex(16)
+	}
+
+	svr, err := client.NewService(cfg, map[string]config.ProxyConf{}, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	return &frpClient{
+		Service:   svr,
+		statusURL: fmt.Sprintf("http://%s/api/status", net.JoinHostPort(cfg.AdminAddr, strconv.Itoa(cfg.AdminPort))),
+		adminUser: cfg.AdminUser,
+		adminPwd:  cfg.AdminPwd,
+		http:      &http.Client{Timeout: adminTimeout},
+	}, nil
+}
+
+type frpClient struct {
+	*client.Service
+
+	statusURL           string
+	adminUser, adminPwd string
+	http                *http.Client
+}
+
+// ProxyStatus 读取 frpc admin 接口 /api/status，覆盖所有代理类型
+// 未登录成功前 admin 接口尚未监听，且接口在没有 Control 时会出错，直接返回空
+func (c *frpClient) ProxyStatus() (map[string]client.ProxyStatusResp, error) {
+	status := map[string]client.ProxyStatusResp{}
+	if c.GetController() == nil {
+		return status, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, c.statusURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(c.adminUser, c.adminPwd)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("frpc admin 接口返回 %s", resp.Status)
+	}
+
+	res := client.StatusResp{}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, err
+	}
+	for _, list := range res {
+		for _, ps := range list {
+			status[ps.Name] = ps
+		}
+	}
+	return status, nil
+}
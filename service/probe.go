@@ -0,0 +1,111 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/douguohai/frp-client/logger"
+	"github.com/douguohai/frp-client/message"
+	"github.com/fatedier/frp/pkg/consts"
+)
+
+// probeTimeout 单次探测本地服务的超时时间
+const probeTimeout = 2 * time.Second
+
+// probeClient 探测用的 HTTP 客户端，不跟随跳转，以便校验原始状态码
+var probeClient = &http.Client{
+	Timeout: probeTimeout,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// ProbeLocal 并发探测已开启代理的本地服务，状态变化时写库并推送
+// udp 代理无法通过连接判断本地服务，正在测试 PROXY 协议的代理本地端口由测试监听占用，均不做探测
+func (s *ProxyService) ProbeLocal() {
+	records := s.Records("")
+	testing := s.testingProxies()
+
+	type result struct {
+		status string
+		err    string
+	}
+	results := make([]result, len(records))
+
+	var wg sync.WaitGroup
+	for i, proxy := range records {
+		if !proxy.Status || proxyTypeOf(proxy) == consts.UDPProxy || testing[proxy.ProxyName] {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, proxy message.ProxyMsg) {
+			defer wg.Done()
+			if err := probeLocal(proxy); err != nil {
+				results[i] = result{status: message.LocalStatusDown, err: err.Error()}
+				return
+			}
+			results[i] = result{status: message.LocalStatusUp}
+		}(i, proxy)
+	}
+	wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 探测期间记录可能已被修改，重新读取后只更新本地服务状态
+	current := map[string]message.ProxyMsg{}
+	for _, proxy := range s.Records("") {
+		current[proxy.ProxyName] = proxy
+	}
+	for i, probed := range records {
+		if testing[probed.ProxyName] {
+			continue
+		}
+		proxy, ok := current[probed.ProxyName]
+		if !ok || proxy.LocalPort != probed.LocalPort || proxy.ProbePath != probed.ProbePath || proxy.ProbeStatus != probed.ProbeStatus {
+			continue
+		}
+		if proxy.LocalStatus == results[i].status && proxy.LocalErr == results[i].err {
+			continue
+		}
+		proxy.LocalStatus = results[i].status
+		proxy.LocalErr = results[i].err
+		if err := s.store.Write(ProxyCollection, proxy.ProxyName, proxy); err != nil {
+			logger.Error("保存本地服务探测结果失败", "proxy", proxy.ProxyName, "err", err)
+			continue
+		}
+		logger.Info("本地服务状态变化", "proxy", proxy.ProxyName, "status", proxy.LocalStatus, "err", proxy.LocalErr)
+		s.publishProxy(proxy)
+	}
+}
+
+// probeLocal 探测本地服务，未设置探测路径时只检查 TCP 端口能否连接
+func probeLocal(proxy message.ProxyMsg) error {
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(proxy.LocalPort))
+	if proxy.ProbePath == "" {
+		conn, err := net.DialTimeout("tcp", addr, probeTimeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	resp, err := probeClient.Get("http://" + addr + proxy.ProbePath)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	want := proxy.ProbeStatus
+	if want == 0 {
+		want = http.StatusOK
+	}
+	if resp.StatusCode != want {
+		return fmt.Errorf("返回状态码 %d，期望 %d", resp.StatusCode, want)
+	}
+	return nil
+}
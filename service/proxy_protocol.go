@@ -0,0 +1,247 @@
+package service
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/douguohai/frp-client/logger"
+	"github.com/douguohai/frp-client/message"
+	proxyproto "github.com/pires/go-proxyproto"
+)
+
+// ErrLocalPortInUse 本地端口已被占用，无法启动测试监听
+var ErrLocalPortInUse = errors.New("本地端口已被占用，请先停止本地服务再测试")
+
+const (
+	// protocolTestDuration 测试监听自动停止的时间
+	protocolTestDuration = 5 * time.Minute
+	// protocolTestRecords 保留最近的连接记录数
+	protocolTestRecords = 20
+	// protocolTestReadTimeout 读取协议头的超时时间
+	protocolTestReadTimeout = 5 * time.Second
+	// protocolTestPeekTimeout 等待访问者请求数据的时间，用于判断是否为 HTTP 请求
+	protocolTestPeekTimeout = 500 * time.Millisecond
+)
+
+// protocolTest 代替本地服务监听本地端口，解析 frpc 发送的 PROXY 协议头
+type protocolTest struct {
+	name      string
+	port      int
+	listener  net.Listener
+	timer     *time.Timer
+	expiresAt int64
+
+	mu      sync.Mutex
+	records []message.ProtocolRecord
+}
+
+// StartProtocolTest 启动 PROXY 协议测试监听，本地服务需要先停止以让出端口
+// 已在测试时重新计算自动停止时间
+func (s *ProxyService) StartProtocolTest(name string) (message.ProtocolTest, error) {
+	proxys := s.Records(strings.Trim(name, " "))
+	if len(proxys) != 1 {
+		return message.ProtocolTest{}, ErrProxyNotFound
+	}
+	proxy := proxys[0]
+	if proxy.ProxyProtocolVersion == "" {
+		return message.ProtocolTest{}, &ValidationError{Fields: []message.FieldError{{
+			Field: "proxyProtocolVersion",
+			Msg:   "代理未开启 PROXY 协议",
+		}}}
+	}
+
+	s.testMu.Lock()
+	defer s.testMu.Unlock()
+
+	if t, ok := s.tests[proxy.ProxyName]; ok {
+		if t.port == proxy.LocalPort {
+			t.timer.Reset(protocolTestDuration)
+			t.expiresAt = time.Now().Add(protocolTestDuration).UnixNano()
+			return t.status(), nil
+		}
+		s.stopProtocolTest(t)
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(proxy.LocalPort)))
+	if err != nil {
+		logger.Warn("PROXY 协议测试监听失败", "proxy", proxy.ProxyName, "err", err)
+		return message.ProtocolTest{}, fmt.Errorf("%w: %d", ErrLocalPortInUse, proxy.LocalPort)
+	}
+	t := &protocolTest{
+		name:      proxy.ProxyName,
+		port:      proxy.LocalPort,
+		listener:  listener,
+		expiresAt: time.Now().Add(protocolTestDuration).UnixNano(),
+		records:   []message.ProtocolRecord{},
+	}
+	t.timer = time.AfterFunc(protocolTestDuration, func() {
+		s.testMu.Lock()
+		defer s.testMu.Unlock()
+		if s.tests[t.name] == t {
+			s.stopProtocolTest(t)
+		}
+	})
+	s.tests[proxy.ProxyName] = t
+	go t.serve()
+
+	logger.Info("PROXY 协议测试监听已启动", "proxy", t.name, "port", t.port)
+	return t.status(), nil
+}
+
+// ProtocolTest 获取测试监听状态和最近收到的连接
+func (s *ProxyService) ProtocolTest(name string) (message.ProtocolTest, error) {
+	proxys := s.Records(strings.Trim(name, " "))
+	if len(proxys) != 1 {
+		return message.ProtocolTest{}, ErrProxyNotFound
+	}
+
+	s.testMu.Lock()
+	defer s.testMu.Unlock()
+	if t, ok := s.tests[proxys[0].ProxyName]; ok {
+		return t.status(), nil
+	}
+	return message.ProtocolTest{
+		ProxyName: proxys[0].ProxyName,
+		LocalPort: proxys[0].LocalPort,
+		Items:     []message.ProtocolRecord{},
+	}, nil
+}
+
+// StopProtocolTest 停止测试监听，未在测试时不做处理
+func (s *ProxyService) StopProtocolTest(name string) {
+	s.testMu.Lock()
+	defer s.testMu.Unlock()
+	if t, ok := s.tests[strings.Trim(name, " ")]; ok {
+		s.stopProtocolTest(t)
+	}
+}
+
+// syncProtocolTest 代理改名、修改本地端口或关闭 PROXY 协议后，按原配置启动的测试监听不再有效
+func (s *ProxyService) syncProtocolTest(name string, proxy message.ProxyMsg) {
+	s.testMu.Lock()
+	defer s.testMu.Unlock()
+	t, ok := s.tests[name]
+	if !ok {
+		return
+	}
+	if t.name != proxy.ProxyName || t.port != proxy.LocalPort || proxy.ProxyProtocolVersion == "" {
+		s.stopProtocolTest(t)
+	}
+}
+
+// testingProxies 正在测试 PROXY 协议的代理，本地端口由测试监听占用
+func (s *ProxyService) testingProxies() map[string]bool {
+	s.testMu.Lock()
+	defer s.testMu.Unlock()
+	names := map[string]bool{}
+	for name := range s.tests {
+		names[name] = true
+	}
+	return names
+}
+
+// stopProtocolTest 调用方需持有 testMu
+func (s *ProxyService) stopProtocolTest(t *protocolTest) {
+	t.timer.Stop()
+	t.listener.Close()
+	delete(s.tests, t.name)
+	logger.Info("PROXY 协议测试监听已停止", "proxy", t.name)
+}
+
+// status 调用方需持有 testMu
+func (t *protocolTest) status() message.ProtocolTest {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	records := make([]message.ProtocolRecord, len(t.records))
+	copy(records, t.records)
+	return message.ProtocolTest{
+		ProxyName: t.name,
+		LocalPort: t.port,
+		Running:   true,
+		ExpiresAt: t.expiresAt,
+		Items:     records,
+	}
+}
+
+func (t *protocolTest) serve() {
+	for {
+		conn, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+		go t.handle(conn)
+	}
+}
+
+// handle 解析协议头并把结果回复给访问者，HTTP 请求回复 HTTP 响应以便在浏览器中查看
+func (t *protocolTest) handle(conn net.Conn) {
+	defer conn.Close()
+	record := message.ProtocolRecord{
+		Time:     time.Now().UnixNano(),
+		PeerAddr: conn.RemoteAddr().String(),
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(protocolTestReadTimeout))
+	reader := bufio.NewReader(conn)
+	// 健康检查只建立连接不发送数据，不计入记录
+	if _, err := reader.Peek(1); err == io.EOF {
+		return
+	}
+	header, err := proxyproto.Read(reader)
+	if err != nil {
+		record.Err = err.Error()
+	} else {
+		record.Version = int(header.Version)
+		record.SourceAddr = addrString(header.SourceAddr)
+		record.DestAddr = addrString(header.DestinationAddr)
+	}
+	t.add(record)
+
+	var body string
+	if record.Err != "" {
+		body = fmt.Sprintf("未收到 PROXY 协议头: %s\n", record.Err)
+	} else {
+		body = fmt.Sprintf("PROXY protocol v%d\nsource: %s\ndestination: %s\n", record.Version, record.SourceAddr, record.DestAddr)
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(protocolTestPeekTimeout))
+	if isHTTPRequest(reader) {
+		body = fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Length: %d\r\nConnection: close\r\n\r\n%s", len(body), body)
+	}
+	_ = conn.SetWriteDeadline(time.Now().Add(protocolTestReadTimeout))
+	_, _ = conn.Write([]byte(body))
+}
+
+func (t *protocolTest) add(record message.ProtocolRecord) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.records = append([]message.ProtocolRecord{record}, t.records...)
+	if len(t.records) > protocolTestRecords {
+		t.records = t.records[:protocolTestRecords]
+	}
+}
+
+// isHTTPRequest 访问者数据以 HTTP 方法开头时视为 HTTP 请求
+func isHTTPRequest(reader *bufio.Reader) bool {
+	prefix, _ := reader.Peek(8)
+	for _, method := range []string{"GET ", "HEAD ", "POST ", "PUT ", "DELETE ", "OPTIONS "} {
+		if strings.HasPrefix(string(prefix), method) {
+			return true
+		}
+	}
+	return false
+}
+
+func addrString(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	return addr.String()
+}
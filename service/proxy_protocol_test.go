@@ -0,0 +1,60 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/douguohai/frp-client/message"
+	"github.com/douguohai/frp-client/utils"
+)
+
+// 本地服务探测和健康检查只建立连接不发送数据，不应计入测试记录
+func TestProtocolTestIgnoresEmptyConns(t *testing.T) {
+	s := newTestProxyService(t)
+	port, err := utils.GetAvailablePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addTestProxy(t, s, message.ProxyMsg{ProxyName: "pp", LocalPort: port, ProxyProtocolVersion: "v1"})
+	if _, err := s.SetEnabled(SystemActor, "pp", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.StartProtocolTest("pp"); err != nil {
+		t.Fatal(err)
+	}
+	defer s.StopProtocolTest("pp")
+
+	s.ProbeLocal()
+	if records := s.Records("pp"); records[0].LocalStatus != "" {
+		t.Fatalf("测试期间探测了本地服务: %+v", records[0])
+	}
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	conn, err = net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("PROXY TCP4 10.0.0.1 10.0.0.2 4321 80\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Read(make([]byte, 64)); err != nil {
+		t.Fatal(err)
+	}
+
+	test, err := s.ProtocolTest("pp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(test.Items) != 1 || test.Items[0].Err != "" || test.Items[0].SourceAddr != "10.0.0.1:4321" {
+		t.Fatalf("测试记录不符合预期: %+v", test.Items)
+	}
+}
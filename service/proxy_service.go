@@ -0,0 +1,752 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/douguohai/frp-client/logger"
+	"github.com/douguohai/frp-client/message"
+	"github.com/fatedier/frp/client/proxy"
+	"github.com/fatedier/frp/pkg/config"
+	"github.com/fatedier/frp/pkg/consts"
+)
+
+// ProxyCollection 代理在存储中的 collection
+const ProxyCollection = "proxys"
+
+var (
+	ErrProxyExists   = errors.New("该服务已经存在,请更换服务名")
+	ErrProxyNotFound = errors.New("不存在该名称的代理")
+	ErrProxyConf     = errors.New("核验配置错误")
+	// ErrReloadFailed 修改已保存，但 frpc 重新加载代理配置失败
+	ErrReloadFailed = errors.New("修改已保存，重新加载代理配置失败")
+)
+
+// ProxyService 代理增删改查及运行状态同步
+type ProxyService struct {
+	store     Store
+	conn      *ConnectionService
+	publisher Publisher
+
+	// mu 串行化代理记录的读改写，避免状态同步与修改互相覆盖
+	mu sync.Mutex
+
+	// PortRange 查找空闲远程端口时的候选端口
+	PortRange []int
+
+	// Audit 记录代理的增删改和开关，为 nil 时不记录
+	Audit *AuditService
+
+	// scheduler 定时开关，提供下一次切换时间，为 nil 时不展示
+	scheduler *Scheduler
+
+	// tests PROXY 协议测试监听，按代理名称索引
+	testMu sync.Mutex
+	tests  map[string]*protocolTest
+
+	// statsMu 串行化流量统计记录的读改写
+	statsMu sync.Mutex
+
+	// dashCache frps dashboard 上的代理信息缓存
+	dashMu    sync.Mutex
+	dashCache dashboardCache
+}
+
+// NewProxyService 创建代理服务，并与连接服务关联
+func NewProxyService(store Store, conn *ConnectionService, publisher Publisher) *ProxyService {
+	s := &ProxyService{
+		store:     store,
+		conn:      conn,
+		publisher: publisher,
+		tests:     map[string]*protocolTest{},
+	}
+	conn.proxies = s
+	return s
+}
+
+// Add 添加代理
+// actor 操作来源，proxy 代理信息
+func (s *ProxyService) Add(actor message.AuditActor, proxy message.ProxyMsg) error {
+	proxy.ProxyName = strings.Trim(proxy.ProxyName, " ")
+	proxy.Group = strings.TrimSpace(proxy.Group)
+	proxy.ProxyProtocolVersion = strings.ToLower(proxy.ProxyProtocolVersion)
+	proxy.Schedule = trimSchedule(proxy.Schedule)
+	proxy.ExpireAction = strings.ToLower(proxy.ExpireAction)
+	proxy.Type = strings.ToLower(proxy.Type)
+	if proxy.Type == "" {
+		proxy.Type = consts.TCPProxy
+	}
+
+	// 重名检测、校验和写入在同一把锁内完成，避免并发添加同名代理
+	s.mu.Lock()
+	proxy, err := s.add(proxy)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	logger.Info("新增代理", "proxy", proxy.ProxyName)
+	s.Audit.Record(actor, message.AuditActionAdd, proxy.ProxyName, nil, auditProxy(proxy), nil)
+	s.publishProxy(proxy)
+	return nil
+}
+
+func (s *ProxyService) add(proxy message.ProxyMsg) (message.ProxyMsg, error) {
+	if err := s.validate(proxy, ""); err != nil {
+		return proxy, err
+	}
+	if err := checkExpireAt(proxy, 0); err != nil {
+		return proxy, err
+	}
+	resolveExpireIn(&proxy, time.Now())
+
+	//判断是否存在重名服务，只检测本地名称
+	proxys := s.Records(proxy.ProxyName)
+	if len(proxys) != 0 {
+		return proxy, ErrProxyExists
+	}
+
+	proxy.AddTime = time.Now().UnixNano()
+	proxy.RemoteProxyName = fmt.Sprintf("%v_%v", proxy.ProxyName, proxy.AddTime)
+	proxy.Status = false
+	proxy.RunStatus, proxy.RemoteAddr, proxy.Err, proxy.ErrReason = "", "", "", ""
+	proxy.LocalStatus, proxy.LocalErr = "", ""
+	proxy.HealthStatus, proxy.HealthCheckAt = "", 0
+
+	_, err := ProxyConf(proxy)
+	if err != nil {
+		logger.Warn("代理配置校验失败", "proxy", proxy.ProxyName, "err", err)
+		return proxy, ErrProxyConf
+	}
+
+	if err := s.store.Write(ProxyCollection, proxy.ProxyName, proxy); err != nil {
+		logger.Error("保存代理失败", "proxy", proxy.ProxyName, "err", err)
+		return proxy, errors.New("添加数据库失败")
+	}
+	return proxy, nil
+}
+
+// Create 添加代理并返回展示信息
+func (s *ProxyService) Create(actor message.AuditActor, proxy message.ProxyMsg) (message.ProxyMsgVo, error) {
+	if err := s.Add(actor, proxy); err != nil {
+		return message.ProxyMsgVo{}, err
+	}
+	return s.Get(strings.Trim(proxy.ProxyName, " "))
+}
+
+// Update 修改代理全部字段，name 为修改前的名称，类型为空时保持不变
+// 改名时移动记录并重新生成远程代理名称；开启状态保持不变，重新加载时只重启配置变化的代理
+func (s *ProxyService) Update(actor message.AuditActor, name string, proxy message.ProxyMsg) (message.ProxyMsgVo, error) {
+	s.mu.Lock()
+	before := s.Records(strings.Trim(name, " "))
+	updated, changed, err := s.update(name, proxy)
+	s.mu.Unlock()
+	if err != nil {
+		return message.ProxyMsgVo{}, err
+	}
+
+	if changed {
+		logger.Info("修改代理", "proxy", updated.ProxyName, "from", strings.Trim(name, " "))
+		s.Audit.Record(actor, message.AuditActionEdit, before[0].ProxyName, auditProxy(before[0]), auditProxy(updated), nil)
+		s.syncProtocolTest(strings.Trim(name, " "), updated)
+		err := s.Reload()
+		s.publishProxy(updated)
+		if err != nil {
+			return message.ProxyMsgVo{}, err
+		}
+	}
+	return s.Get(updated.ProxyName)
+}
+
+func (s *ProxyService) update(name string, proxy message.ProxyMsg) (message.ProxyMsg, bool, error) {
+	proxys := s.Records(strings.Trim(name, " "))
+	if len(proxys) != 1 {
+		return message.ProxyMsg{}, false, ErrProxyNotFound
+	}
+	old := proxys[0]
+
+	temp := old
+	temp.ProxyName = strings.Trim(proxy.ProxyName, " ")
+	if proxy.Type != "" {
+		temp.Type = strings.ToLower(proxy.Type)
+	}
+	temp.LocalPort = proxy.LocalPort
+	temp.RemotePort = proxy.RemotePort
+	temp.ProbePath = proxy.ProbePath
+	temp.ProbeStatus = proxy.ProbeStatus
+	temp.HealthCheck = proxy.HealthCheck
+	temp.Group = strings.TrimSpace(proxy.Group)
+	temp.GroupKey = proxy.GroupKey
+	// 展示信息不返回组密钥，组名不变时留空表示保持原密钥，退出分组时清空
+	if temp.GroupKey == "" && temp.Group == old.Group {
+		temp.GroupKey = old.GroupKey
+	}
+	if temp.Group == "" {
+		temp.GroupKey = ""
+	}
+	temp.ProxyProtocolVersion = strings.ToLower(proxy.ProxyProtocolVersion)
+	temp.Stats = proxy.Stats
+	temp.Schedule = trimSchedule(proxy.Schedule)
+	temp.ExpireAt = proxy.ExpireAt
+	temp.ExpireIn = proxy.ExpireIn
+	temp.ExpireAction = strings.ToLower(proxy.ExpireAction)
+	if temp.HealthCheck != old.HealthCheck {
+		temp.HealthStatus, temp.HealthCheckAt = "", 0
+	}
+	renamed := temp.ProxyName != old.ProxyName
+	if renamed {
+		temp.RemoteProxyName = fmt.Sprintf("%v_%v", temp.ProxyName, temp.AddTime)
+	}
+
+	if err := s.validate(temp, old.ProxyName); err != nil {
+		return message.ProxyMsg{}, false, err
+	}
+	if err := checkExpireAt(temp, old.ExpireAt); err != nil {
+		return message.ProxyMsg{}, false, err
+	}
+	resolveExpireIn(&temp, time.Now())
+	if _, err := ProxyConf(temp); err != nil {
+		logger.Warn("代理配置校验失败", "proxy", temp.ProxyName, "err", err)
+		return message.ProxyMsg{}, false, ErrProxyConf
+	}
+	if temp == old {
+		return temp, false, nil
+	}
+
+	if renamed {
+		if len(s.Records(temp.ProxyName)) != 0 {
+			return message.ProxyMsg{}, false, ErrProxyExists
+		}
+		err := s.store.Move(ProxyCollection, old.ProxyName, temp.ProxyName, temp)
+		if errors.Is(err, ErrRecordExists) {
+			return message.ProxyMsg{}, false, ErrProxyExists
+		}
+		if err != nil {
+			logger.Error("代理改名失败", "proxy", old.ProxyName, "to", temp.ProxyName, "err", err)
+			return message.ProxyMsg{}, false, errors.New("修改异常")
+		}
+		s.renameStats(old.ProxyName, temp.ProxyName)
+	} else if err := s.store.Write(ProxyCollection, temp.ProxyName, temp); err != nil {
+		logger.Error("保存代理失败", "proxy", temp.ProxyName, "err", err)
+		return message.ProxyMsg{}, false, errors.New("修改异常")
+	}
+	return temp, true, nil
+}
+
+// Patch 修改代理中传入的字段
+func (s *ProxyService) Patch(actor message.AuditActor, name string, patch message.ProxyPatch) (message.ProxyMsgVo, error) {
+	proxys := s.Records(strings.Trim(name, " "))
+	if len(proxys) != 1 {
+		return message.ProxyMsgVo{}, ErrProxyNotFound
+	}
+	proxy := proxys[0]
+	if patch.ProxyName != nil {
+		proxy.ProxyName = *patch.ProxyName
+	}
+	if patch.Type != nil {
+		proxy.Type = *patch.Type
+	}
+	if patch.LocalPort != nil {
+		proxy.LocalPort = *patch.LocalPort
+	}
+	if patch.RemotePort != nil {
+		proxy.RemotePort = *patch.RemotePort
+	}
+	if patch.ProbePath != nil {
+		proxy.ProbePath = *patch.ProbePath
+	}
+	if patch.ProbeStatus != nil {
+		proxy.ProbeStatus = *patch.ProbeStatus
+	}
+	if patch.HealthCheck != nil {
+		proxy.HealthCheck = *patch.HealthCheck
+	}
+	if patch.Group != nil {
+		proxy.Group = *patch.Group
+	}
+	if patch.GroupKey != nil {
+		proxy.GroupKey = *patch.GroupKey
+	}
+	if patch.ProxyProtocolVersion != nil {
+		proxy.ProxyProtocolVersion = *patch.ProxyProtocolVersion
+	}
+	if patch.Stats != nil {
+		proxy.Stats = *patch.Stats
+	}
+	if patch.Schedule != nil {
+		proxy.Schedule = *patch.Schedule
+	}
+	if patch.ExpireAt != nil {
+		proxy.ExpireAt = *patch.ExpireAt
+	}
+	if patch.ExpireIn != nil {
+		proxy.ExpireIn = *patch.ExpireIn
+	}
+	if patch.ExpireAction != nil {
+		proxy.ExpireAction = *patch.ExpireAction
+	}
+	return s.Update(actor, name, proxy)
+}
+
+// Delete 删除代理
+func (s *ProxyService) Delete(actor message.AuditActor, name string) error {
+	s.mu.Lock()
+	proxys := s.Records(strings.Trim(name, " "))
+	if len(proxys) != 1 {
+		s.mu.Unlock()
+		return ErrProxyNotFound
+	}
+	temp := proxys[0]
+
+	err := s.store.Delete(ProxyCollection, temp.ProxyName)
+	s.mu.Unlock()
+	if err != nil {
+		logger.Error("删除代理失败", "proxy", temp.ProxyName, "err", err)
+		return errors.New("删除失败")
+	}
+	s.StopProtocolTest(temp.ProxyName)
+	s.deleteStats(temp.ProxyName)
+	logger.Info("删除代理", "proxy", temp.ProxyName)
+	s.Audit.Record(actor, message.AuditActionDelete, temp.ProxyName, auditProxy(temp), nil, nil)
+	s.publisher.Publish(message.EventProxy, message.ProxyEvent{ProxyName: temp.ProxyName, Deleted: true})
+
+	//判断当前代理如果处于运行中,等待关闭，重新刷新配置
+	return s.Reload()
+}
+
+// SetEnabled 开启或关闭代理并返回展示信息
+// 代理被关闭后，到期时间随之取消，到期操作为删除的代理不再被删除
+func (s *ProxyService) SetEnabled(actor message.AuditActor, name string, enabled bool) (message.ProxyMsgVo, error) {
+	return s.setEnabled(actor, name, enabled, nil)
+}
+
+// EnableWithExpiry 开启代理并设置到期，expiry 各项为空时保持原有到期设置
+func (s *ProxyService) EnableWithExpiry(actor message.AuditActor, name string, expiry message.ProxyExpiry) (message.ProxyMsgVo, error) {
+	return s.setEnabled(actor, name, true, func(p *message.ProxyMsg) error {
+		if expiry.ExpireIn != "" || expiry.ExpireAt != 0 {
+			p.ExpireIn, p.ExpireAt = expiry.ExpireIn, expiry.ExpireAt
+		}
+		if expiry.ExpireAction != "" {
+			p.ExpireAction = strings.ToLower(expiry.ExpireAction)
+		}
+		v := &validator{}
+		validateExpiry(v, *p)
+		if err := v.err(); err != nil {
+			return err
+		}
+		if expiry.ExpireIn == "" && expiry.ExpireAt != 0 {
+			if err := checkExpireAt(*p, 0); err != nil {
+				return err
+			}
+		}
+		resolveExpireIn(p, time.Now())
+		return nil
+	})
+}
+
+// setEnabled 修改开启状态，apply 在保存前修改代理记录，返回错误时不保存
+func (s *ProxyService) setEnabled(actor message.AuditActor, name string, enabled bool, apply func(*message.ProxyMsg) error) (message.ProxyMsgVo, error) {
+	s.mu.Lock()
+	proxys := s.Records(strings.Trim(name, " "))
+	if len(proxys) != 1 {
+		s.mu.Unlock()
+		return message.ProxyMsgVo{}, ErrProxyNotFound
+	}
+	temp := proxys[0]
+	before := temp
+
+	temp.Status = enabled
+	if !enabled {
+		temp.ExpireAt = 0
+	}
+	if apply != nil {
+		if err := apply(&temp); err != nil {
+			s.mu.Unlock()
+			return message.ProxyMsgVo{}, err
+		}
+	}
+
+	err := s.store.Write(ProxyCollection, temp.ProxyName, temp)
+	s.mu.Unlock()
+	if err != nil {
+		logger.Error("保存代理状态失败", "proxy", temp.ProxyName, "err", err)
+		return message.ProxyMsgVo{}, errors.New("开启失败")
+	}
+	logger.Info("修改代理开启状态", "proxy", temp.ProxyName, "enabled", enabled)
+	action := message.AuditActionDisable
+	if enabled {
+		action = message.AuditActionEnable
+	}
+	s.Audit.Record(actor, action, temp.ProxyName, auditProxy(before), auditProxy(temp), nil)
+
+	err = s.Reload()
+	s.publishProxy(temp)
+	if err != nil {
+		return message.ProxyMsgVo{}, err
+	}
+	return s.Get(temp.ProxyName)
+}
+
+// Get 按名称获取代理展示信息
+func (s *ProxyService) Get(name string) (message.ProxyMsgVo, error) {
+	for _, proxy := range s.List() {
+		if proxy.ProxyName == name {
+			return proxy, nil
+		}
+	}
+	return message.ProxyMsgVo{}, ErrProxyNotFound
+}
+
+// List 获取代理列表，按添加时间排序
+func (s *ProxyService) List() []message.ProxyMsgVo {
+	proxys := s.Records("")
+
+	values := make([]message.ProxyMsgVo, 0)
+	servers := s.serverProxies()
+
+	for _, value := range proxys {
+		proxyType := strings.ToLower(value.Type)
+		switch proxyType {
+		case consts.TCPProxy, consts.UDPProxy:
+			vo := proxyVo(value)
+			if p, ok := servers[value.RemoteProxyName]; ok {
+				applyServerProxy(&vo, p)
+			}
+			if s.scheduler != nil {
+				if next, status, ok := s.scheduler.Next(value.ProxyName); ok {
+					vo.NextTransition, vo.NextStatus = next.UnixNano(), status
+				}
+			}
+			values = append(values, vo)
+		}
+	}
+	//对value 进行排序
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].AddTime < values[j].AddTime
+	})
+	return values
+}
+
+// Groups 负载均衡组列表，按组名排序
+func (s *ProxyService) Groups() []message.ProxyGroup {
+	groups := map[string]*message.ProxyGroup{}
+	for _, proxy := range s.List() {
+		if proxy.Group == "" {
+			continue
+		}
+		group, ok := groups[proxy.Group]
+		if !ok {
+			group = &message.ProxyGroup{
+				Group:      proxy.Group,
+				Type:       proxyTypeOf(message.ProxyMsg{Type: proxy.Type}),
+				RemotePort: proxy.RemotePort,
+				Members:    []string{},
+			}
+			groups[proxy.Group] = group
+		}
+		group.Members = append(group.Members, proxy.ProxyName)
+		if proxy.Status {
+			group.Enabled++
+		}
+	}
+
+	values := make([]message.ProxyGroup, 0, len(groups))
+	for _, group := range groups {
+		values = append(values, *group)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].Group < values[j].Group
+	})
+	return values
+}
+
+// proxyVo 代理记录转换为展示信息
+func proxyVo(value message.ProxyMsg) message.ProxyMsgVo {
+	return message.ProxyMsgVo{
+		ProxyName:  value.ProxyName,
+		Type:       value.Type,
+		LocalPort:  value.LocalPort,
+		RemotePort: value.RemotePort,
+		Status:     value.Status,
+		RemoteAddr: value.RemoteAddr,
+		AddTime:    value.AddTime,
+		Err:        value.Err,
+		ErrReason:  value.ErrReason,
+		RunStatus:  value.RunStatus,
+
+		ProbePath:   value.ProbePath,
+		ProbeStatus: value.ProbeStatus,
+		LocalStatus: value.LocalStatus,
+		LocalErr:    value.LocalErr,
+
+		HealthCheck:   value.HealthCheck,
+		HealthStatus:  value.HealthStatus,
+		HealthCheckAt: value.HealthCheckAt,
+
+		Group:       value.Group,
+		HasGroupKey: value.GroupKey != "",
+
+		ProxyProtocolVersion: value.ProxyProtocolVersion,
+
+		Stats: value.Stats,
+
+		Schedule: value.Schedule,
+
+		ExpireAt:     value.ExpireAt,
+		ExpireAction: value.ExpireAction,
+		ExpiresInS:   expiresInS(value.ExpireAt, time.Now()),
+	}
+}
+
+// expiresInS 距到期的秒数，不足一秒按一秒计，不到期或已到期时为 0
+func expiresInS(expireAt int64, now time.Time) int64 {
+	if expireAt == 0 || expireAt <= now.UnixNano() {
+		return 0
+	}
+	return (expireAt - now.UnixNano() + int64(time.Second) - 1) / int64(time.Second)
+}
+
+// resolveExpireIn 将有效时长换算为到期时间
+func resolveExpireIn(proxy *message.ProxyMsg, now time.Time) {
+	if proxy.ExpireIn == "" {
+		return
+	}
+	if d, err := time.ParseDuration(proxy.ExpireIn); err == nil {
+		proxy.ExpireAt = now.Add(d).UnixNano()
+	}
+	proxy.ExpireIn = ""
+}
+
+// trimSchedule 去掉定时配置各项首尾的空白
+func trimSchedule(conf message.ScheduleConf) message.ScheduleConf {
+	return message.ScheduleConf{
+		Cron:     strings.TrimSpace(conf.Cron),
+		Windows:  strings.TrimSpace(conf.Windows),
+		Timezone: strings.TrimSpace(conf.Timezone),
+	}
+}
+
+// Exclusive 暂停定时任务并持有代理记录锁执行 fn，用于恢复备份时整体替换存储目录
+// fn 内不能调用代理服务的修改方法
+func (s *ProxyService) Exclusive(fn func() error) error {
+	if s.scheduler != nil {
+		defer s.scheduler.Suspend()()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fn()
+}
+
+// Records 数据库获取代理信息
+// filter 过滤字段，代理名称，为空时返回全部
+func (s *ProxyService) Records(filter string) []message.ProxyMsg {
+	proxys := []message.ProxyMsg{}
+	records, err := s.store.ReadAll(ProxyCollection)
+	if err != nil {
+		logger.Error("读取代理失败", "err", err)
+		return proxys
+	}
+	for _, f := range records {
+		temp := message.ProxyMsg{}
+		if err := json.Unmarshal(f, &temp); err != nil {
+			logger.Error("解析代理记录失败", "err", err)
+		}
+		if filter == "" || temp.ProxyName == filter {
+			proxys = append(proxys, temp)
+		}
+	}
+	return proxys
+}
+
+// CloseAll 将全部代理置为关闭
+func (s *ProxyService) CloseAll() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	for _, temp := range s.Records("") {
+		temp.Status = false
+		temp.RemoteAddr = "暂无"
+		temp.RunStatus = proxy.ProxyPhaseClosed
+		temp.Err = ""
+		temp.ErrReason = ""
+		temp.HealthStatus = ""
+		if werr := s.store.Write(ProxyCollection, temp.ProxyName, temp); werr != nil {
+			logger.Error("保存代理状态失败", "proxy", temp.ProxyName, "err", werr)
+			err = errors.New("关闭失败")
+		}
+	}
+	return err
+}
+
+// Reload 按数据库中的预期状态重新加载代理，失败时返回 ErrReloadFailed
+func (s *ProxyService) Reload() error {
+	proxyConfList := map[string]config.ProxyConf{}
+	for _, temp := range s.Records("") {
+		//如果预期状态为打开，进行配置文件转换
+		if temp.Status {
+			cfg, err := ProxyConf(temp)
+			if err != nil {
+				logger.Warn("代理配置校验失败，跳过加载", "proxy", temp.ProxyName, "err", err)
+				continue
+			}
+			proxyConfList[temp.RemoteProxyName] = cfg
+		}
+	}
+	if err := s.conn.Reload(proxyConfList); err != nil {
+		return fmt.Errorf("%w: %v", ErrReloadFailed, err)
+	}
+	return nil
+}
+
+// SyncStatus 同步代理运行状态到数据库，状态变化时推送事件
+func (s *ProxyService) SyncStatus() {
+	proxyRunStatus, err := s.conn.ProxyStatus()
+	if err != nil {
+		// 读取失败时保持上一次的状态，不将代理标记为关闭
+		logger.Warn("读取代理运行状态失败", "err", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, localTemp := range s.Records("") {
+		before := localTemp
+		temp, has := proxyRunStatus[localTemp.RemoteProxyName]
+		if !has {
+			localTemp.Status = false
+			localTemp.RemoteAddr = "暂无"
+		} else {
+			localTemp.RemoteAddr = temp.RemoteAddr
+		}
+		localTemp.RunStatus = temp.Status
+		localTemp.Err = temp.Err
+		localTemp.ErrReason = ProxyErrReason(temp.Err)
+		syncHealth(&localTemp)
+
+		//状态无变化不写库，不推送
+		if before == localTemp {
+			continue
+		}
+		if err := s.store.Write(ProxyCollection, localTemp.ProxyName, localTemp); err != nil {
+			logger.Error("保存代理状态失败", "proxy", localTemp.ProxyName, "err", err)
+		}
+		s.publishProxy(localTemp)
+		if localTemp.Err != "" {
+			s.conn.publishError("proxy", localTemp.ProxyName, localTemp.Err)
+		}
+	}
+}
+
+// syncHealth 根据代理运行状态更新健康检查结果
+// frp 不暴露健康检查的具体错误，检查失败时附带最近一次本地服务探测的结果
+func syncHealth(p *message.ProxyMsg) {
+	status := p.HealthStatus
+	switch {
+	case p.HealthCheck.Type == "" || !p.Status:
+		status = ""
+	case p.RunStatus == proxy.ProxyPhaseCheckFailed:
+		status = message.HealthStatusFailed
+		p.ErrReason = message.ProxyErrHealthCheck
+		p.Err = "健康检查失败，代理已下线"
+		if p.LocalErr != "" {
+			p.Err += ": " + p.LocalErr
+		}
+	case p.RunStatus == proxy.ProxyPhaseRunning:
+		status = message.HealthStatusPassed
+	}
+	if status != p.HealthStatus {
+		p.HealthStatus = status
+		p.HealthCheckAt = time.Now().UnixNano()
+	}
+}
+
+func (s *ProxyService) publishProxy(proxy message.ProxyMsg) {
+	s.publisher.Publish(message.EventProxy, message.ProxyEvent{
+		ProxyName:  proxy.ProxyName,
+		Status:     proxy.Status,
+		RunStatus:  proxy.RunStatus,
+		RemoteAddr: proxy.RemoteAddr,
+		Err:        proxy.Err,
+		ErrReason:  proxy.ErrReason,
+
+		LocalStatus: proxy.LocalStatus,
+		LocalErr:    proxy.LocalErr,
+
+		HealthStatus: proxy.HealthStatus,
+	})
+}
+
+// validate 校验代理配置，origin 为修改前的名称，不参与重复检测；frps 端口不能作为远程端口
+func (s *ProxyService) validate(proxy message.ProxyMsg, origin string) error {
+	var reserved []int
+	if server := s.conn.Server(); server.ServerPort != 0 {
+		reserved = append(reserved, server.ServerPort)
+	}
+	others := []message.ProxyMsg{}
+	for _, other := range s.Records("") {
+		if other.ProxyName != origin {
+			others = append(others, other)
+		}
+	}
+	return ValidateProxy(proxy, others, reserved)
+}
+
+// ProxyConf 代理信息转换为 frpc 配置并校验
+func ProxyConf(proxy message.ProxyMsg) (config.ProxyConf, error) {
+	base := config.BaseProxyConf{}
+	base.ProxyName = proxy.RemoteProxyName
+	base.LocalIP = "127.0.0.1"
+	base.LocalPort = proxy.LocalPort
+	base.Plugin, base.PluginParams = statsPluginParams(proxy, net.JoinHostPort(base.LocalIP, strconv.Itoa(proxy.LocalPort)))
+	base.UseEncryption = false
+	base.UseCompression = false
+	base.BandwidthLimit, _ = config.NewBandwidthQuantity("")
+	base.BandwidthLimitMode = config.BandwidthLimitModeClient
+	base.HealthCheckConf = healthCheckConf(proxy)
+	base.Group = proxy.Group
+	base.GroupKey = proxy.GroupKey
+	base.ProxyProtocolVersion = proxy.ProxyProtocolVersion
+
+	var cfg config.ProxyConf
+	switch strings.ToLower(proxy.Type) {
+	case "", consts.TCPProxy:
+		base.ProxyType = consts.TCPProxy
+		cfg = &config.TCPProxyConf{BaseProxyConf: base, RemotePort: proxy.RemotePort}
+	case consts.UDPProxy:
+		base.ProxyType = consts.UDPProxy
+		cfg = &config.UDPProxyConf{BaseProxyConf: base, RemotePort: proxy.RemotePort}
+	default:
+		return nil, fmt.Errorf("不支持的代理类型 %s", proxy.Type)
+	}
+
+	return cfg, cfg.ValidateForClient()
+}
+
+// healthCheckConf 转换为 frp 健康检查配置，检查地址固定为本地服务地址
+func healthCheckConf(proxy message.ProxyMsg) config.HealthCheckConf {
+	hc := proxy.HealthCheck
+	cfg := config.HealthCheckConf{
+		HealthCheckType:      strings.ToLower(hc.Type),
+		HealthCheckTimeoutS:  hc.TimeoutS,
+		HealthCheckMaxFailed: hc.MaxFailed,
+		HealthCheckIntervalS: hc.IntervalS,
+	}
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(proxy.LocalPort))
+	switch cfg.HealthCheckType {
+	case "tcp":
+		cfg.HealthCheckAddr = addr
+	case "http":
+		if hc.URL != "" {
+			cfg.HealthCheckURL = "http://" + addr + hc.URL
+		}
+	}
+	return cfg
+}
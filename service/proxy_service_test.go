@@ -0,0 +1,334 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/douguohai/frp-client/message"
+	"github.com/fatedier/frp/client"
+	"github.com/fatedier/frp/pkg/config"
+)
+
+// recordPublisher 记录推送的事件
+type recordPublisher struct {
+	mu     sync.Mutex
+	events []message.Event
+}
+
+func (p *recordPublisher) Publish(eventType string, body interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, message.Event{Type: eventType, Body: body})
+}
+
+// newTestProxyService 数据保存在临时目录、未连接服务器的代理服务
+func newTestProxyService(t *testing.T) *ProxyService {
+	t.Helper()
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	publisher := &recordPublisher{}
+	s := NewProxyService(store, NewConnectionService(NewFrpClient, publisher), publisher)
+	s.Audit = NewAuditService(store)
+	return s
+}
+
+// fakeStore 内存存储，writeErr、deleteErr 不为空时对应操作失败
+type fakeStore struct {
+	mu        sync.Mutex
+	records   map[string]map[string][]byte
+	writeErr  error
+	deleteErr error
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{records: map[string]map[string][]byte{}}
+}
+
+func (s *fakeStore) Read(collection, resource string, v interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.records[collection][resource]
+	if !ok {
+		return os.ErrNotExist
+	}
+	return json.Unmarshal(b, v)
+}
+
+func (s *fakeStore) ReadAll(collection string) ([][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var all [][]byte
+	for _, b := range s.records[collection] {
+		all = append(all, b)
+	}
+	return all, nil
+}
+
+func (s *fakeStore) Write(collection, resource string, v interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.writeErr != nil {
+		return s.writeErr
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if s.records[collection] == nil {
+		s.records[collection] = map[string][]byte{}
+	}
+	s.records[collection][resource] = b
+	return nil
+}
+
+func (s *fakeStore) Delete(collection, resource string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.deleteErr != nil {
+		return s.deleteErr
+	}
+	delete(s.records[collection], resource)
+	return nil
+}
+
+func (s *fakeStore) Move(collection, from, to string, v interface{}) error {
+	if err := s.Write(collection, to, v); err != nil {
+		return err
+	}
+	return s.Delete(collection, from)
+}
+
+// fakeFrpClient 登录后一直运行到 Close，reloadErr 不为空时重新加载失败
+type fakeFrpClient struct {
+	mu        sync.Mutex
+	reloads   []map[string]config.ProxyConf
+	reloadErr error
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (c *fakeFrpClient) Run(ctx context.Context) error {
+	<-c.closed
+	return nil
+}
+
+func (c *fakeFrpClient) ReloadConf(pxyCfgs map[string]config.ProxyConf, _ map[string]config.VisitorConf) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reloads = append(c.reloads, pxyCfgs)
+	return c.reloadErr
+}
+
+func (c *fakeFrpClient) Close() {
+	c.closeOnce.Do(func() { close(c.closed) })
+}
+
+func (c *fakeFrpClient) ProxyStatus() (map[string]client.ProxyStatusResp, error) {
+	return map[string]client.ProxyStatusResp{}, nil
+}
+
+// reloadCount 重新加载的次数
+func (c *fakeFrpClient) reloadCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.reloads)
+}
+
+// newFakeProxyService 使用内存存储、已连接 fake frpc 的代理服务
+func newFakeProxyService(t *testing.T) (*ProxyService, *fakeStore, *fakeFrpClient) {
+	t.Helper()
+	wait := connectWait
+	connectWait = 10 * time.Millisecond
+	defer func() { connectWait = wait }()
+
+	store := newFakeStore()
+	frpc := &fakeFrpClient{closed: make(chan struct{})}
+	publisher := &recordPublisher{}
+	conn := NewConnectionService(func(config.ClientCommonConf) (FrpClient, error) {
+		return frpc, nil
+	}, publisher)
+	s := NewProxyService(store, conn, publisher)
+	// 审计使用单独的存储，代理存储写入失败时仍能确认没有审计记录
+	s.Audit = NewAuditService(newFakeStore())
+	if err := conn.Connect(SystemActor, message.ConnectServerMsg{ServerIp: "127.0.0.1", ServerPort: 7000}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(frpc.Close)
+	return s, store, frpc
+}
+
+// bodies 推送的指定类型的事件内容
+func (p *recordPublisher) bodies(eventType string) []interface{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var bodies []interface{}
+	for _, e := range p.events {
+		if e.Type == eventType {
+			bodies = append(bodies, e.Body)
+		}
+	}
+	return bodies
+}
+
+// auditRecords 查询审计记录，失败时结束测试
+func auditRecords(t *testing.T, s *ProxyService, f AuditFilter) []message.AuditRecord {
+	t.Helper()
+	records, err := s.Audit.Query(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+// addTestProxy 新增代理，失败时结束测试
+func addTestProxy(t *testing.T, s *ProxyService, proxy message.ProxyMsg) {
+	t.Helper()
+	if proxy.LocalPort == 0 {
+		proxy.LocalPort = 8000
+	}
+	if err := s.Add(SystemActor, proxy); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 新增、开关和删除代理都会推送代理事件，界面据此刷新列表
+func TestProxyEvents(t *testing.T) {
+	s := newTestProxyService(t)
+	publisher := s.publisher.(*recordPublisher)
+	addTestProxy(t, s, message.ProxyMsg{ProxyName: "web"})
+	if _, err := s.SetEnabled(SystemActor, "web", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(SystemActor, "web"); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []message.ProxyEvent
+	for _, e := range publisher.events {
+		if e.Type == message.EventProxy {
+			got = append(got, e.Body.(message.ProxyEvent))
+		}
+	}
+	if len(got) != 3 {
+		t.Fatalf("代理事件数为 %d: %+v", len(got), got)
+	}
+	for i, e := range got {
+		if e.ProxyName != "web" || e.Deleted != (i == 2) {
+			t.Fatalf("第 %d 个代理事件为 %+v", i, e)
+		}
+	}
+}
+
+// 并发添加同名代理时只有一个成功
+func TestAddConcurrentSameName(t *testing.T) {
+	s := newTestProxyService(t)
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- s.Add(SystemActor, message.ProxyMsg{ProxyName: "web", LocalPort: 8000})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	added := 0
+	for err := range errs {
+		if err == nil {
+			added++
+		} else if err != ErrProxyExists {
+			t.Fatal(err)
+		}
+	}
+	if added != 1 || len(s.Records("web")) != 1 {
+		t.Fatalf("成功添加 %d 次，记录数为 %d", added, len(s.Records("web")))
+	}
+}
+
+// 保存失败时返回错误，不推送事件、不记录审计、不重新加载
+func TestStoreWriteFailure(t *testing.T) {
+	s, store, frpc := newFakeProxyService(t)
+	publisher := s.publisher.(*recordPublisher)
+	addTestProxy(t, s, message.ProxyMsg{ProxyName: "web"})
+	events, audits, reloads := len(publisher.bodies(message.EventProxy)), len(auditRecords(t, s, AuditFilter{})), frpc.reloadCount()
+
+	store.writeErr = errors.New("disk full")
+	if err := s.Add(SystemActor, message.ProxyMsg{ProxyName: "api", LocalPort: 8001}); err == nil {
+		t.Fatal("保存失败时新增代理成功")
+	}
+	if _, err := s.SetEnabled(SystemActor, "web", true); err == nil {
+		t.Fatal("保存失败时开启代理成功")
+	}
+	store.writeErr = nil
+
+	if len(s.Records("api")) != 0 || s.Records("web")[0].Status {
+		t.Fatalf("保存失败后记录为 %+v", s.Records(""))
+	}
+	if n := len(publisher.bodies(message.EventProxy)); n != events {
+		t.Fatalf("保存失败后推送了 %d 个代理事件", n-events)
+	}
+	if n := len(auditRecords(t, s, AuditFilter{})); n != audits {
+		t.Fatalf("保存失败后记录了 %d 条审计", n-audits)
+	}
+	if n := frpc.reloadCount(); n != reloads {
+		t.Fatalf("保存失败后重新加载了 %d 次", n-reloads)
+	}
+}
+
+// 删除记录失败时返回错误，记录保留，不推送删除事件
+func TestDeleteStoreFailure(t *testing.T) {
+	s, store, frpc := newFakeProxyService(t)
+	publisher := s.publisher.(*recordPublisher)
+	addTestProxy(t, s, message.ProxyMsg{ProxyName: "web"})
+	reloads := frpc.reloadCount()
+
+	store.deleteErr = errors.New("permission denied")
+	if err := s.Delete(SystemActor, "web"); err == nil {
+		t.Fatal("删除记录失败时返回成功")
+	}
+	if len(s.Records("web")) != 1 {
+		t.Fatal("删除记录失败后记录丢失")
+	}
+	for _, body := range publisher.bodies(message.EventProxy) {
+		if body.(message.ProxyEvent).Deleted {
+			t.Fatal("删除记录失败后推送了删除事件")
+		}
+	}
+	if frpc.reloadCount() != reloads {
+		t.Fatal("删除记录失败后重新加载了代理")
+	}
+}
+
+// 删除后重新加载失败时，记录已删除，返回 ErrReloadFailed 并推送错误事件
+func TestDeleteReloadFailure(t *testing.T) {
+	s, _, frpc := newFakeProxyService(t)
+	publisher := s.publisher.(*recordPublisher)
+	addTestProxy(t, s, message.ProxyMsg{ProxyName: "web"})
+
+	frpc.mu.Lock()
+	frpc.reloadErr = errors.New("reload failed")
+	frpc.mu.Unlock()
+	if err := s.Delete(SystemActor, "web"); !errors.Is(err, ErrReloadFailed) {
+		t.Fatalf("重新加载失败时返回 %v", err)
+	}
+	if len(s.Records("web")) != 0 {
+		t.Fatal("重新加载失败后记录未删除")
+	}
+	errs := publisher.bodies(message.EventError)
+	if len(errs) == 0 || errs[len(errs)-1].(message.ErrorEvent).Source != "reload" {
+		t.Fatalf("错误事件为 %+v", errs)
+	}
+	records := auditRecords(t, s, AuditFilter{Action: message.AuditActionDelete})
+	if len(records) != 1 || records[0].Target != "web" {
+		t.Fatalf("删除审计为 %+v", records)
+	}
+}
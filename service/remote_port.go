@@ -0,0 +1,110 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/douguohai/frp-client/message"
+	"github.com/fatedier/frp/pkg/consts"
+)
+
+// ErrNoFreePort 端口范围内没有找到空闲端口
+var ErrNoFreePort = errors.New("没有找到空闲的远程端口")
+
+const (
+	// freePortAttempts 查找空闲端口时最多尝试的端口数
+	freePortAttempts = 20
+	// freePortDialTimeout 探测单个端口的超时时间
+	freePortDialTimeout = 500 * time.Millisecond
+)
+
+// proxyErrReasons frps 返回的错误信息与错误原因的对应关系
+var proxyErrReasons = []struct {
+	keyword string
+	reason  string
+}{
+	{"port already used", message.ProxyErrPortInUse},
+	{"port unavailable", message.ProxyErrPortInUse},
+	{"port not allowed", message.ProxyErrPortNotAllowed},
+	{"exceed the max_ports_per_client", message.ProxyErrQuotaExceeded},
+	{"no available port", message.ProxyErrNoAvailablePort},
+	{"already exists", message.ProxyErrNameConflict},
+	{"group auth failed", message.ProxyErrGroupConflict},
+	{"group should have same remote port", message.ProxyErrGroupConflict},
+	{"group params invalid", message.ProxyErrGroupConflict},
+}
+
+// ProxyErrReason 解析 frps 返回的代理启动错误，无错误时返回空
+func ProxyErrReason(err string) string {
+	if err == "" {
+		return ""
+	}
+	for _, r := range proxyErrReasons {
+		if strings.Contains(err, r.keyword) {
+			return r.reason
+		}
+	}
+	return message.ProxyErrUnknown
+}
+
+// FreeRemotePort 在 PortRange 中查找空闲的远程端口
+// 跳过已被其他代理使用的端口和 frps 端口；tcp 通过连接服务器探测，连接被拒绝视为空闲，udp 无法探测，只排除已使用的端口
+func (s *ProxyService) FreeRemotePort(proxyType string) (message.FreePort, error) {
+	if !s.conn.Configured() {
+		return message.FreePort{}, ErrServerNotConfigured
+	}
+	server := s.conn.Server()
+	proxyType = strings.ToLower(proxyType)
+	if proxyType == "" {
+		proxyType = consts.TCPProxy
+	}
+	if proxyType != consts.TCPProxy && proxyType != consts.UDPProxy {
+		return message.FreePort{}, &ValidationError{Fields: []message.FieldError{{
+			Field: "type",
+			Msg:   fmt.Sprintf("不支持的代理类型 %s", proxyType),
+		}}}
+	}
+
+	used := map[int]bool{server.ServerPort: true}
+	for _, proxy := range s.Records("") {
+		if proxyTypeOf(proxy) == proxyType {
+			used[proxy.RemotePort] = true
+		}
+	}
+
+	candidates := make([]int, 0, len(s.PortRange))
+	for _, port := range s.PortRange {
+		if !used[port] && port >= remotePortMin && validPort(port) {
+			candidates = append(candidates, port)
+		}
+	}
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	rnd.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	if len(candidates) > freePortAttempts {
+		candidates = candidates[:freePortAttempts]
+	}
+
+	for _, port := range candidates {
+		if proxyType == consts.UDPProxy || remotePortFree(server.ServerIp, port) {
+			return message.FreePort{Type: proxyType, Port: port}, nil
+		}
+	}
+	return message.FreePort{}, ErrNoFreePort
+}
+
+// remotePortFree 连接被拒绝视为空闲；连接成功说明已被占用，超时无法判断，都视为不可用
+func remotePortFree(host string, port int) bool {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, fmt.Sprint(port)), freePortDialTimeout)
+	if err == nil {
+		conn.Close()
+		return false
+	}
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
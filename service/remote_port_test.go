@@ -0,0 +1,31 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/douguohai/frp-client/message"
+)
+
+func TestProxyErrReason(t *testing.T) {
+	cases := []struct {
+		err    string
+		reason string
+	}{
+		{"", ""},
+		{"port already used", message.ProxyErrPortInUse},
+		{"port unavailable", message.ProxyErrPortInUse},
+		{"port not allowed", message.ProxyErrPortNotAllowed},
+		{"exceed the max_ports_per_client", message.ProxyErrQuotaExceeded},
+		{"no available port", message.ProxyErrNoAvailablePort},
+		{"proxy [web_1700000000] already exists", message.ProxyErrNameConflict},
+		{"group auth failed", message.ProxyErrGroupConflict},
+		{"group should have same remote port", message.ProxyErrGroupConflict},
+		{"group params invalid", message.ProxyErrGroupConflict},
+		{"router config conflict", message.ProxyErrUnknown},
+	}
+	for _, c := range cases {
+		if got := ProxyErrReason(c.err); got != c.reason {
+			t.Errorf("ProxyErrReason(%q) = %q，期望 %q", c.err, got, c.reason)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// 部分系统（如 Windows）没有时区数据库，内置一份
+	_ "time/tzdata"
+
+	"github.com/douguohai/frp-client/message"
+)
+
+// scheduleHorizon 查找下一次切换的范围，时间窗口按周重复，多查一天覆盖跨午夜的窗口
+const scheduleHorizon = 8 * 24 * time.Hour
+
+var weekdayNames = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+
+var monthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+// schedule 解析后的定时开关，精确到分钟
+type schedule struct {
+	loc     *time.Location
+	cron    *cronExpr
+	windows []timeWindow
+}
+
+// parseSchedule 解析定时开关配置，未配置时返回 nil
+func parseSchedule(conf message.ScheduleConf) (*schedule, error) {
+	cronSpec, windows := strings.TrimSpace(conf.Cron), strings.TrimSpace(conf.Windows)
+	if cronSpec == "" && windows == "" {
+		return nil, nil
+	}
+	if cronSpec != "" && windows != "" {
+		return nil, fmt.Errorf("cron 表达式和时间窗口只能设置一个")
+	}
+	s := &schedule{loc: time.Local}
+	var err error
+	if tz := strings.TrimSpace(conf.Timezone); tz != "" {
+		if s.loc, err = time.LoadLocation(tz); err != nil {
+			return nil, fmt.Errorf("未知的时区 %s", tz)
+		}
+	}
+	if cronSpec != "" {
+		s.cron, err = parseCron(cronSpec)
+	} else {
+		s.windows, err = parseWindows(windows)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Active t 所在的分钟是否在开启范围内
+func (s *schedule) Active(t time.Time) bool {
+	t = t.In(s.loc)
+	if s.cron != nil {
+		return s.cron.match(t)
+	}
+	for _, w := range s.windows {
+		if w.match(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Next t 之后第一次切换开启状态的时间，scheduleHorizon 内不切换时返回 false
+func (s *schedule) Next(t time.Time) (time.Time, bool) {
+	active := s.Active(t)
+	end := t.Add(scheduleHorizon)
+	for m := t.Truncate(time.Minute).Add(time.Minute); m.Before(end); m = m.Add(time.Minute) {
+		if s.Active(m) != active {
+			return m, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// cronExpr 五段 cron 表达式，每段为命中值的位集合
+type cronExpr struct {
+	minute, hour, dom, month, dow uint64
+	// domAny、dowAny 日和星期为 * 时，按 cron 惯例两者都限定时满足其一即可
+	domAny, dowAny bool
+}
+
+// parseCron 解析 "分 时 日 月 周"，支持 *、列表、范围、步长，月份和星期可用英文缩写，星期 7 同 0
+func parseCron(spec string) (*cronExpr, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron 表达式应为 5 段 \"分 时 日 月 周\"，实际为 %d 段", len(fields))
+	}
+	c := &cronExpr{domAny: fields[2] == "*", dowAny: fields[4] == "*"}
+	var err error
+	for _, f := range []struct {
+		bits     *uint64
+		spec     string
+		name     string
+		min, max int
+		names    map[string]int
+	}{
+		{&c.minute, fields[0], "分钟", 0, 59, nil},
+		{&c.hour, fields[1], "小时", 0, 23, nil},
+		{&c.dom, fields[2], "日", 1, 31, nil},
+		{&c.month, fields[3], "月", 1, 12, monthNames},
+		{&c.dow, fields[4], "星期", 0, 7, weekdayNames},
+	} {
+		if *f.bits, err = parseCronField(f.spec, f.min, f.max, f.names); err != nil {
+			return nil, fmt.Errorf("cron %s段 %q 错误: %v", f.name, f.spec, err)
+		}
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	return c, nil
+}
+
+func parseCronField(spec string, min, max int, names map[string]int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(spec, ",") {
+		rng, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("步长 %q 应为正整数", part[i+1:])
+			}
+			rng, step = part[:i], n
+		}
+
+		lo, hi := min, max
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if lo, err = cronValue(bounds[0], min, max, names); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = cronValue(bounds[1], min, max, names); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				// "5/15" 表示从 5 开始到最大值
+				hi = max
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("范围 %q 起始大于结束", rng)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func cronValue(s string, min, max int, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < min || v > max {
+		return 0, fmt.Errorf("%q 应为 %d-%d", s, min, max)
+	}
+	return v, nil
+}
+
+func (c *cronExpr) match(t time.Time) bool {
+	if c.minute&(1<<uint(t.Minute())) == 0 || c.hour&(1<<uint(t.Hour())) == 0 || c.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// timeWindow 每周重复的时间窗口，结束早于开始时跨越午夜，跨越部分属于次日
+type timeWindow struct {
+	days       [7]bool
+	start, end int // 一天中的分钟数，end 可为 1440
+}
+
+// parseWindows 解析 "mon-fri 09:00-18:00; sat,sun 10:00-12:00"，省略星期为每天
+func parseWindows(spec string) ([]timeWindow, error) {
+	var windows []timeWindow
+	for _, part := range strings.Split(spec, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		fields := strings.Fields(part)
+		w := timeWindow{}
+		switch len(fields) {
+		case 1:
+			for i := range w.days {
+				w.days[i] = true
+			}
+		case 2:
+			if err := parseWindowDays(fields[0], &w.days); err != nil {
+				return nil, fmt.Errorf("时间窗口 %q 错误: %v", part, err)
+			}
+		default:
+			return nil, fmt.Errorf("时间窗口 %q 应为 \"星期 开始-结束\"，如 mon-fri 09:00-18:00", part)
+		}
+
+		times := strings.SplitN(fields[len(fields)-1], "-", 2)
+		if len(times) != 2 {
+			return nil, fmt.Errorf("时间窗口 %q 缺少结束时间", part)
+		}
+		var err error
+		if w.start, err = clockMinutes(times[0], false); err != nil {
+			return nil, fmt.Errorf("时间窗口 %q 错误: %v", part, err)
+		}
+		if w.end, err = clockMinutes(times[1], true); err != nil {
+			return nil, fmt.Errorf("时间窗口 %q 错误: %v", part, err)
+		}
+		if w.start == w.end {
+			return nil, fmt.Errorf("时间窗口 %q 开始与结束相同", part)
+		}
+		windows = append(windows, w)
+	}
+	if len(windows) == 0 {
+		return nil, fmt.Errorf("时间窗口为空")
+	}
+	return windows, nil
+}
+
+func parseWindowDays(spec string, days *[7]bool) error {
+	for _, part := range strings.Split(spec, ",") {
+		bounds := strings.SplitN(part, "-", 2)
+		lo, ok := weekdayNames[strings.ToLower(bounds[0])]
+		if !ok {
+			return fmt.Errorf("未知的星期 %q，可选 mon tue wed thu fri sat sun", bounds[0])
+		}
+		hi := lo
+		if len(bounds) == 2 {
+			if hi, ok = weekdayNames[strings.ToLower(bounds[1])]; !ok {
+				return fmt.Errorf("未知的星期 %q，可选 mon tue wed thu fri sat sun", bounds[1])
+			}
+		}
+		// fri-mon 跨越周末
+		for d := lo; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == hi {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// clockMinutes 解析 "HH:MM" 为一天中的分钟数，allowEnd 时允许 24:00
+func clockMinutes(s string, allowEnd bool) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err == nil {
+		return t.Hour()*60 + t.Minute(), nil
+	}
+	if allowEnd && s == "24:00" {
+		return 24 * 60, nil
+	}
+	return 0, fmt.Errorf("时间 %q 应为 HH:MM", s)
+}
+
+func (w timeWindow) match(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	day := int(t.Weekday())
+	if w.start < w.end {
+		return w.days[day] && m >= w.start && m < w.end
+	}
+	return (w.days[day] && m >= w.start) || (w.days[(day+6)%7] && m < w.end)
+}
@@ -0,0 +1,281 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/douguohai/frp-client/message"
+)
+
+// bits 值的位集合
+func bits(values ...int) uint64 {
+	var b uint64
+	for _, v := range values {
+		b |= 1 << uint(v)
+	}
+	return b
+}
+
+// span lo 到 hi 的位集合
+func span(lo, hi, step int) uint64 {
+	var b uint64
+	for v := lo; v <= hi; v += step {
+		b |= 1 << uint(v)
+	}
+	return b
+}
+
+func TestParseCron(t *testing.T) {
+	cases := []struct {
+		spec string
+		want cronExpr
+	}{
+		{"* * * * *", cronExpr{minute: span(0, 59, 1), hour: span(0, 23, 1), dom: span(1, 31, 1), month: span(1, 12, 1), dow: span(0, 7, 1), domAny: true, dowAny: true}},
+		{"*/15 9-17 1,15 jan-mar mon-fri", cronExpr{minute: bits(0, 15, 30, 45), hour: span(9, 17, 1), dom: bits(1, 15), month: bits(1, 2, 3), dow: span(1, 5, 1)}},
+		{"5/20 0-12/6 * DEC sun", cronExpr{minute: bits(5, 25, 45), hour: bits(0, 6, 12), dom: span(1, 31, 1), month: bits(12), dow: bits(0), domAny: true}},
+		// 星期 7 同 0
+		{"0 0 * * 7", cronExpr{minute: bits(0), hour: bits(0), dom: span(1, 31, 1), month: span(1, 12, 1), dow: bits(0, 7), domAny: true}},
+	}
+	for _, c := range cases {
+		got, err := parseCron(c.spec)
+		if err != nil {
+			t.Errorf("parseCron(%q): %v", c.spec, err)
+			continue
+		}
+		if *got != c.want {
+			t.Errorf("parseCron(%q) = %+v，期望 %+v", c.spec, *got, c.want)
+		}
+	}
+
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"30-10 * * * *",
+		"* * * * fri-mon",
+		"* * * * someday",
+	} {
+		if _, err := parseCron(spec); err == nil {
+			t.Errorf("parseCron(%q) 未返回错误", spec)
+		}
+	}
+}
+
+// TestCronMatch 日和星期都限定时满足其一即可，其中一个为 * 时两者都需满足
+func TestCronMatch(t *testing.T) {
+	cases := []struct {
+		spec string
+		at   time.Time
+		want bool
+	}{
+		// 2024-01-01 为周一，2024-02-01 为周四
+		{"0 9 1 * mon", time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC), true},
+		{"0 9 1 * mon", time.Date(2024, 2, 1, 9, 0, 0, 0, time.UTC), true},
+		{"0 9 1 * mon", time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC), true},
+		{"0 9 1 * mon", time.Date(2024, 1, 9, 9, 0, 0, 0, time.UTC), false},
+		{"0 9 1 * mon", time.Date(2024, 1, 8, 9, 1, 0, 0, time.UTC), false},
+		{"0 9 1 * *", time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC), false},
+		{"0 9 1 * *", time.Date(2024, 2, 1, 9, 0, 0, 0, time.UTC), true},
+		{"0 9 * * mon", time.Date(2024, 2, 1, 9, 0, 0, 0, time.UTC), false},
+		{"0 9 * * mon", time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC), true},
+		{"* * * feb *", time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC), false},
+		{"* * * * 7", time.Date(2024, 1, 7, 12, 0, 0, 0, time.UTC), true},
+	}
+	for _, c := range cases {
+		cron, err := parseCron(c.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := cron.match(c.at); got != c.want {
+			t.Errorf("%q 在 %s 匹配为 %v，期望 %v", c.spec, c.at.Format("2006-01-02 Mon 15:04"), got, c.want)
+		}
+	}
+}
+
+func TestParseWindows(t *testing.T) {
+	everyday := [7]bool{true, true, true, true, true, true, true}
+	weekdays := [7]bool{false, true, true, true, true, true, false}
+	cases := []struct {
+		spec string
+		want []timeWindow
+	}{
+		{"09:00-18:00", []timeWindow{{days: everyday, start: 540, end: 1080}}},
+		{"mon-fri 09:00-18:00; sat,sun 10:00-12:00;", []timeWindow{
+			{days: weekdays, start: 540, end: 1080},
+			{days: [7]bool{true, false, false, false, false, false, true}, start: 600, end: 720},
+		}},
+		// fri-mon 跨越周末
+		{"Fri-Mon 22:00-02:00", []timeWindow{{days: [7]bool{true, true, false, false, false, true, true}, start: 1320, end: 120}}},
+		{"sat 00:00-24:00", []timeWindow{{days: [7]bool{6: true}, start: 0, end: 1440}}},
+	}
+	for _, c := range cases {
+		got, err := parseWindows(c.spec)
+		if err != nil {
+			t.Errorf("parseWindows(%q): %v", c.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("parseWindows(%q) = %+v，期望 %+v", c.spec, got, c.want)
+		}
+	}
+
+	for _, spec := range []string{
+		"",
+		" ; ",
+		"mon",
+		"mon 09:00",
+		"mon tue 09:00-10:00",
+		"someday 09:00-10:00",
+		"mon-someday 09:00-10:00",
+		"09:00-09:00",
+		"24:00-10:00",
+		"25:00-26:00",
+		"9am-5pm",
+	} {
+		if _, err := parseWindows(spec); err == nil {
+			t.Errorf("parseWindows(%q) 未返回错误", spec)
+		}
+	}
+}
+
+// TestTimeWindowMatch 跨午夜的窗口，午夜之后的部分按前一天的星期判断
+func TestTimeWindowMatch(t *testing.T) {
+	windows, err := parseWindows("fri-mon 22:00-02:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := windows[0]
+	// 2024-01-05 为周五
+	day := func(d, hour, min int) time.Time {
+		return time.Date(2024, 1, d, hour, min, 0, 0, time.UTC)
+	}
+	cases := []struct {
+		at   time.Time
+		want bool
+	}{
+		{day(5, 21, 59), false},
+		{day(5, 22, 0), true},
+		{day(5, 23, 59), true},
+		{day(6, 1, 59), true},
+		{day(6, 2, 0), false},
+		{day(7, 23, 0), true},
+		// 周一晚开始的窗口延续到周二凌晨
+		{day(9, 1, 0), true},
+		{day(9, 23, 0), false},
+		{day(10, 1, 0), false},
+		// 周四不在范围内，周五凌晨不开启
+		{day(5, 1, 0), false},
+	}
+	for _, c := range cases {
+		if got := w.match(c.at); got != c.want {
+			t.Errorf("%s 匹配为 %v，期望 %v", c.at.Format("Mon 15:04"), got, c.want)
+		}
+	}
+
+	end, err := parseWindows("sat 22:00-24:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !end[0].match(day(6, 23, 59)) || end[0].match(day(7, 0, 0)) {
+		t.Error("24:00 结束的窗口不应延续到次日")
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	utc := func(month time.Month, d, hour, min int) time.Time {
+		return time.Date(2024, month, d, hour, min, 0, 0, time.UTC)
+	}
+	cases := []struct {
+		name    string
+		conf    message.ScheduleConf
+		from    time.Time
+		active  bool
+		next    time.Time
+		hasNext bool
+	}{
+		{"窗口内", message.ScheduleConf{Windows: "mon 09:00-10:00", Timezone: "UTC"}, utc(1, 1, 9, 30), true, utc(1, 1, 10, 0), true},
+		{"秒数不影响分钟", message.ScheduleConf{Windows: "mon 09:00-10:00", Timezone: "UTC"}, utc(1, 1, 8, 59).Add(30 * time.Second), false, utc(1, 1, 9, 0), true},
+		{"下周", message.ScheduleConf{Windows: "mon 09:00-10:00", Timezone: "UTC"}, utc(1, 1, 10, 0), false, utc(1, 8, 9, 0), true},
+		{"跨午夜", message.ScheduleConf{Windows: "fri 22:00-02:00", Timezone: "UTC"}, utc(1, 5, 23, 0), true, utc(1, 6, 2, 0), true},
+		{"跨周末", message.ScheduleConf{Windows: "fri-mon 22:00-02:00", Timezone: "UTC"}, utc(1, 6, 2, 0), false, utc(1, 6, 22, 0), true},
+		{"相邻窗口连续开启", message.ScheduleConf{Windows: "22:00-24:00; 00:00-01:00", Timezone: "UTC"}, utc(1, 1, 23, 0), true, utc(1, 2, 1, 0), true},
+		{"时区", message.ScheduleConf{Cron: "* 9-17 * * mon-fri", Timezone: "Asia/Shanghai"}, utc(1, 6, 2, 0), false, utc(1, 8, 1, 0), true},
+		{"一直开启", message.ScheduleConf{Windows: "00:00-24:00", Timezone: "UTC"}, utc(1, 1, 0, 0), true, time.Time{}, false},
+		{"不存在的日期", message.ScheduleConf{Cron: "* * 31 feb *", Timezone: "UTC"}, utc(1, 1, 0, 0), false, time.Time{}, false},
+		// 2024-03-10 夏令时开始，02:00 跳到 03:00
+		{"夏令时开始时窗口提前结束", message.ScheduleConf{Windows: "01:30-02:30", Timezone: "America/New_York"},
+			time.Date(2024, 3, 10, 1, 45, 0, 0, newYork), true, utc(3, 10, 7, 0), true},
+		{"夏令时开始时跳过的时间不触发", message.ScheduleConf{Cron: "* 2 * * *", Timezone: "America/New_York"},
+			time.Date(2024, 3, 10, 1, 0, 0, 0, newYork), false, time.Date(2024, 3, 11, 2, 0, 0, 0, newYork), true},
+		// 2024-11-03 夏令时结束，01:00-02:00 重复一次
+		{"夏令时结束时窗口再次开启", message.ScheduleConf{Windows: "01:00-01:30", Timezone: "America/New_York"},
+			utc(11, 3, 5, 30), false, utc(11, 3, 6, 0), true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s, err := parseSchedule(c.conf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Active(c.from); got != c.active {
+				t.Fatalf("%s 开启状态为 %v", c.from, got)
+			}
+			next, ok := s.Next(c.from)
+			if ok != c.hasNext || !next.Equal(c.next) {
+				t.Fatalf("下一次切换为 %v %v，期望 %v %v", next.UTC(), ok, c.next.UTC(), c.hasNext)
+			}
+		})
+	}
+}
+
+func TestParseSchedule(t *testing.T) {
+	if s, err := parseSchedule(message.ScheduleConf{Timezone: "UTC"}); s != nil || err != nil {
+		t.Fatalf("未配置时返回 %v %v", s, err)
+	}
+	for _, conf := range []message.ScheduleConf{
+		{Cron: "* * * * *", Windows: "09:00-18:00"},
+		{Cron: "* * * * *", Timezone: "Mars/Olympus"},
+		{Windows: "09:00"},
+	} {
+		if _, err := parseSchedule(conf); err == nil {
+			t.Errorf("%+v 未返回错误", conf)
+		}
+	}
+	s, err := parseSchedule(message.ScheduleConf{Windows: "09:00-18:00"})
+	if err != nil || s.loc != time.Local {
+		t.Fatalf("未设置时区时为 %v %v", s.loc, err)
+	}
+}
+
+// 暂停期间到期的任务等待恢复后执行，Suspend 等待执行中的任务结束
+func TestSchedulerSuspend(t *testing.T) {
+	s := NewScheduler(newTestProxyService(t))
+	ran := make(chan struct{}, 1)
+	s.Every("test", time.Second, func() { ran <- struct{}{} })
+
+	resume := s.Suspend()
+	s.runTasks(time.Now())
+	select {
+	case <-ran:
+		t.Fatal("暂停期间执行了任务")
+	case <-time.After(100 * time.Millisecond):
+	}
+	resume()
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("恢复后任务未执行")
+	}
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/douguohai/frp-client/logger"
+	"github.com/douguohai/frp-client/message"
+)
+
+// ScheduleActor 定时开关的操作来源
+var ScheduleActor = message.AuditActor{Source: message.AuditSourceSystem, Client: "schedule"}
+
+// Scheduler 每秒执行到期的周期任务，按定时配置切换代理的开启状态，并处理代理到期
+// 定时开关只在首次检查和到达切换时间时生效，其间的手动开关保持到下一次切换
+type Scheduler struct {
+	proxies *ProxyService
+	tasks   []*scheduledTask
+
+	mu     sync.Mutex
+	states map[string]*scheduleState
+
+	// runMu 周期任务和定时检查执行时持有读锁，Suspend 持有写锁
+	runMu sync.RWMutex
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// scheduledTask 周期任务，上一次未执行完时跳过本次
+type scheduledTask struct {
+	name     string
+	interval time.Duration
+	run      func()
+	next     time.Time
+	running  int32
+}
+
+// scheduleState 代理定时开关的检查状态，按代理名称索引
+type scheduleState struct {
+	conf    message.ScheduleConf
+	sched   *schedule
+	checked bool
+	active  bool
+	// next 下一次需要检查的时间，transition 为 false 时只是查找范围的终点
+	next       time.Time
+	transition bool
+	// warned 已推送即将到期事件的到期时间
+	warned int64
+}
+
+// NewScheduler 创建调度器，并与代理服务关联
+func NewScheduler(proxies *ProxyService) *Scheduler {
+	s := &Scheduler{
+		proxies: proxies,
+		states:  map[string]*scheduleState{},
+		stop:    make(chan struct{}),
+	}
+	proxies.scheduler = s
+	return s
+}
+
+// Every 注册周期任务，首次在 interval 之后执行，Run 之前调用
+func (s *Scheduler) Every(name string, interval time.Duration, run func()) {
+	s.tasks = append(s.tasks, &scheduledTask{name: name, interval: interval, run: run})
+}
+
+// Run 每秒执行一次调度，Stop 后返回
+func (s *Scheduler) Run() {
+	now := time.Now()
+	for _, t := range s.tasks {
+		t.next = now.Add(t.interval)
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.runTasks(now)
+			s.Evaluate(now)
+		}
+	}
+}
+
+// Stop 停止调度
+func (s *Scheduler) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+// Suspend 等待执行中的任务结束并暂停调度，调用返回的函数后恢复
+func (s *Scheduler) Suspend() (resume func()) {
+	s.runMu.Lock()
+	return s.runMu.Unlock
+}
+
+// runTasks 各任务在单独的协程执行，慢任务不阻塞其他任务
+func (s *Scheduler) runTasks(now time.Time) {
+	for _, t := range s.tasks {
+		if now.Before(t.next) {
+			continue
+		}
+		t.next = now.Add(t.interval)
+		if !atomic.CompareAndSwapInt32(&t.running, 0, 1) {
+			logger.Debug("周期任务未执行完，跳过本次", "task", t.name)
+			continue
+		}
+		go func(t *scheduledTask) {
+			defer atomic.StoreInt32(&t.running, 0)
+			s.runMu.RLock()
+			defer s.runMu.RUnlock()
+			t.run()
+		}(t)
+	}
+}
+
+// Evaluate 按 now 处理到期的代理，并检查全部代理的定时配置，需要切换时修改代理的预期开启状态
+func (s *Scheduler) Evaluate(now time.Time) {
+	type change struct {
+		name    string
+		enabled bool
+	}
+	var changes []change
+	var expired, expiring []message.ProxyMsg
+
+	s.runMu.RLock()
+	defer s.runMu.RUnlock()
+
+	s.mu.Lock()
+	seen := map[string]bool{}
+	for _, p := range s.proxies.Records("") {
+		seen[p.ProxyName] = true
+		st := s.states[p.ProxyName]
+		if st == nil || st.conf != p.Schedule {
+			sched, err := parseSchedule(p.Schedule)
+			if err != nil {
+				logger.Warn("定时配置错误，不执行定时开关", "proxy", p.ProxyName, "err", err)
+			}
+			next := &scheduleState{conf: p.Schedule, sched: sched}
+			if st != nil {
+				next.warned = st.warned
+			}
+			st = next
+			s.states[p.ProxyName] = st
+		}
+
+		if p.ExpireAt != 0 {
+			deadline := time.Unix(0, p.ExpireAt)
+			if !now.Before(deadline) {
+				expired = append(expired, p)
+				continue
+			}
+			if deadline.Sub(now) <= ExpireWarning && st.warned != p.ExpireAt {
+				st.warned = p.ExpireAt
+				expiring = append(expiring, p)
+			}
+		}
+
+		if st.sched == nil || (st.checked && now.Before(st.next)) {
+			continue
+		}
+
+		// 到达切换时间后按当前范围设置，即使期间未检查（如休眠）跨过了整个窗口
+		active := st.sched.Active(now)
+		if (!st.checked || st.transition || active != st.active) && active != p.Status {
+			changes = append(changes, change{name: p.ProxyName, enabled: active})
+		}
+		st.checked, st.active = true, active
+		if next, ok := st.sched.Next(now); ok {
+			st.next, st.transition = next, true
+		} else {
+			st.next, st.transition = now.Add(scheduleHorizon), false
+		}
+	}
+	for name := range s.states {
+		if !seen[name] {
+			delete(s.states, name)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, p := range expiring {
+		s.proxies.publishExpiring(p, now)
+	}
+	for _, p := range expired {
+		s.proxies.expire(p)
+	}
+	for _, c := range changes {
+		logger.Info("定时切换代理开启状态", "proxy", c.name, "enabled", c.enabled)
+		if _, err := s.proxies.SetEnabled(ScheduleActor, c.name, c.enabled); err != nil {
+			logger.Warn("定时切换代理开启状态失败", "proxy", c.name, "err", err)
+		}
+	}
+}
+
+// Next 代理下一次定时切换的时间和切换后的开启状态，尚未检查或范围内不切换时返回 false
+func (s *Scheduler) Next(name string) (time.Time, bool, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.states[name]
+	if st == nil || !st.checked || !st.transition {
+		return time.Time{}, false, false
+	}
+	return st.next, !st.active, true
+}
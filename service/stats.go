@@ -0,0 +1,251 @@
+package service
+
+import (
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/douguohai/frp-client/logger"
+	"github.com/douguohai/frp-client/message"
+	"github.com/fatedier/frp/pkg/consts"
+	plugin "github.com/fatedier/frp/pkg/plugin/client"
+	libio "github.com/fatedier/golib/io"
+)
+
+// StatsCollection 流量统计在存储中的 collection
+const StatsCollection = "stats"
+
+const (
+	// statsPluginName frpc 插件名，代替 frpc 连接本地服务并统计流量
+	statsPluginName = "frp_client_stats"
+	// statsRetention 时间序列保留时长
+	statsRetention = 24 * time.Hour
+	// statsDialTimeout 连接本地服务的超时时间，与 frpc 一致
+	statsDialTimeout = 10 * time.Second
+)
+
+// trafficCounter 单个代理的流量计数，字节数和新连接数在采样时清零，当前连接数不清零
+type trafficCounter struct {
+	bytesIn  int64
+	bytesOut int64
+	newConns int64
+	curConns int64
+}
+
+// trafficCounters 代理名称与流量计数的对应关系，frpc 插件按名称累加
+type trafficCounters struct {
+	mu       sync.Mutex
+	counters map[string]*trafficCounter
+}
+
+var traffic = &trafficCounters{counters: map[string]*trafficCounter{}}
+
+func (c *trafficCounters) get(name string) *trafficCounter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counter, ok := c.counters[name]
+	if !ok {
+		counter = &trafficCounter{}
+		c.counters[name] = counter
+	}
+	return counter
+}
+
+func (c *trafficCounters) rename(from, to string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if counter, ok := c.counters[from]; ok {
+		c.counters[to] = counter
+		delete(c.counters, from)
+	}
+}
+
+func (c *trafficCounters) remove(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.counters, name)
+}
+
+func init() {
+	plugin.Register(statsPluginName, func(params map[string]string) (plugin.Plugin, error) {
+		return &statsPlugin{
+			proxyName: params["proxy_name"],
+			localAddr: params["local_addr"],
+			counter:   traffic.get(params["proxy_name"]),
+		}, nil
+	})
+}
+
+// statsPluginParams 开启流量统计的 tcp 代理通过插件连接本地服务，其余代理由 frpc 直接连接
+// udp 代理 frpc 不经过插件，无法统计
+func statsPluginParams(proxy message.ProxyMsg, localAddr string) (string, map[string]string) {
+	if !proxy.Stats || proxyTypeOf(proxy) != consts.TCPProxy {
+		return "", nil
+	}
+	return statsPluginName, map[string]string{
+		"proxy_name": proxy.ProxyName,
+		"local_addr": localAddr,
+	}
+}
+
+// statsPlugin 与 frpc 默认处理相同：连接本地服务，先写入 PROXY 协议头再双向转发，同时统计流量
+type statsPlugin struct {
+	proxyName string
+	localAddr string
+	counter   *trafficCounter
+}
+
+func (p *statsPlugin) Name() string {
+	return statsPluginName
+}
+
+func (p *statsPlugin) Handle(conn io.ReadWriteCloser, realConn net.Conn, extraBufToLocal []byte) {
+	localConn, err := net.DialTimeout("tcp", p.localAddr, statsDialTimeout)
+	if err != nil {
+		conn.Close()
+		logger.Warn("连接本地服务失败", "proxy", p.proxyName, "addr", p.localAddr, "err", err)
+		return
+	}
+	if len(extraBufToLocal) > 0 {
+		if _, err := localConn.Write(extraBufToLocal); err != nil {
+			conn.Close()
+			localConn.Close()
+			logger.Warn("写入 PROXY 协议头失败", "proxy", p.proxyName, "err", err)
+			return
+		}
+	}
+
+	atomic.AddInt64(&p.counter.newConns, 1)
+	atomic.AddInt64(&p.counter.curConns, 1)
+	defer atomic.AddInt64(&p.counter.curConns, -1)
+	libio.Join(&countingConn{Conn: localConn, counter: p.counter}, conn)
+}
+
+func (p *statsPlugin) Close() error {
+	return nil
+}
+
+// countingConn 统计本地连接的读写字节数，写入本地服务为流入，从本地服务读取为流出
+type countingConn struct {
+	net.Conn
+	counter *trafficCounter
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	atomic.AddInt64(&c.counter.bytesOut, int64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddInt64(&c.counter.bytesIn, int64(n))
+	return n, err
+}
+
+// SampleStats 采样全部代理的流量计数，追加到时间序列并累加总量
+// 未开启且没有流量的代理不追加采样点
+func (s *ProxyService) SampleStats() {
+	now := time.Now()
+	for _, proxy := range s.Records("") {
+		counter := traffic.get(proxy.ProxyName)
+		point := message.StatsPoint{
+			Time:     now.UnixNano(),
+			BytesIn:  atomic.SwapInt64(&counter.bytesIn, 0),
+			BytesOut: atomic.SwapInt64(&counter.bytesOut, 0),
+			NewConns: atomic.SwapInt64(&counter.newConns, 0),
+			CurConns: atomic.LoadInt64(&counter.curConns),
+		}
+		if !proxy.Status && point.BytesIn == 0 && point.BytesOut == 0 && point.NewConns == 0 && point.CurConns == 0 {
+			continue
+		}
+
+		s.statsMu.Lock()
+		stats := s.readStats(proxy.ProxyName)
+		stats.BytesIn += point.BytesIn
+		stats.BytesOut += point.BytesOut
+		stats.TotalConns += point.NewConns
+		stats.Items = append(trimStats(stats.Items, now.Add(-statsRetention)), point)
+		if err := s.store.Write(StatsCollection, proxy.ProxyName, stats); err != nil {
+			logger.Error("保存流量统计失败", "proxy", proxy.ProxyName, "err", err)
+		}
+		s.statsMu.Unlock()
+	}
+}
+
+// Stats 获取代理流量统计，window 为时间序列的时长，超出保留时长时按保留时长返回
+// 总量包含尚未采样的流量
+func (s *ProxyService) Stats(name string, window time.Duration) (message.ProxyStats, error) {
+	proxys := s.Records(strings.Trim(name, " "))
+	if len(proxys) != 1 {
+		return message.ProxyStats{}, ErrProxyNotFound
+	}
+	name = proxys[0].ProxyName
+	if window <= 0 || window > statsRetention {
+		window = statsRetention
+	}
+
+	s.statsMu.Lock()
+	stats := s.readStats(name)
+	s.statsMu.Unlock()
+
+	counter := traffic.get(name)
+	stats.BytesIn += atomic.LoadInt64(&counter.bytesIn)
+	stats.BytesOut += atomic.LoadInt64(&counter.bytesOut)
+	stats.TotalConns += atomic.LoadInt64(&counter.newConns)
+	stats.CurConns = atomic.LoadInt64(&counter.curConns)
+	stats.Items = trimStats(stats.Items, time.Now().Add(-window))
+	return stats, nil
+}
+
+// readStats 调用方需持有 statsMu，没有记录时返回空统计
+func (s *ProxyService) readStats(name string) message.ProxyStats {
+	stats := message.ProxyStats{}
+	if err := s.store.Read(StatsCollection, name, &stats); err != nil {
+		stats = message.ProxyStats{}
+	}
+	stats.ProxyName = name
+	if stats.Items == nil {
+		stats.Items = []message.StatsPoint{}
+	}
+	return stats
+}
+
+// renameStats 代理改名时移动统计记录和计数
+func (s *ProxyService) renameStats(from, to string) {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+	traffic.rename(from, to)
+	stats := s.readStats(from)
+	stats.ProxyName = to
+	if err := s.store.Move(StatsCollection, from, to, stats); err != nil {
+		logger.Error("移动流量统计失败", "proxy", from, "to", to, "err", err)
+	}
+}
+
+// deleteStats 删除代理时清除统计
+func (s *ProxyService) deleteStats(name string) {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+	traffic.remove(name)
+	if err := s.store.Read(StatsCollection, name, &message.ProxyStats{}); err != nil {
+		return
+	}
+	if err := s.store.Delete(StatsCollection, name); err != nil {
+		logger.Error("删除流量统计失败", "proxy", name, "err", err)
+	}
+}
+
+// trimStats 去掉 since 之前的采样点，采样点按时间排序
+func trimStats(points []message.StatsPoint, since time.Time) []message.StatsPoint {
+	start := since.UnixNano()
+	for i, point := range points {
+		if point.Time >= start {
+			return points[i:]
+		}
+	}
+	return []message.StatsPoint{}
+}
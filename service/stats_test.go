@@ -0,0 +1,178 @@
+package service
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/douguohai/frp-client/message"
+)
+
+func TestTrimStats(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	points := []message.StatsPoint{
+		{Time: base.UnixNano()},
+		{Time: base.Add(time.Hour).UnixNano()},
+		{Time: base.Add(2 * time.Hour).UnixNano()},
+	}
+	cases := []struct {
+		name  string
+		since time.Time
+		want  int
+	}{
+		{"全部保留", base.Add(-time.Minute), 3},
+		{"边界保留", base.Add(time.Hour), 2},
+		{"只保留最后一个", base.Add(90 * time.Minute), 1},
+		{"全部过期", base.Add(3 * time.Hour), 0},
+	}
+	for _, c := range cases {
+		got := trimStats(points, c.since)
+		if len(got) != c.want || got == nil {
+			t.Errorf("%s: 保留 %d 个，期望 %d 个", c.name, len(got), c.want)
+		}
+		if c.want > 0 && got[len(got)-1] != points[2] {
+			t.Errorf("%s: 最后一个采样点为 %+v", c.name, got[len(got)-1])
+		}
+	}
+}
+
+// TestSampleStatsRetention 采样时去掉超出保留时长的采样点，总量不受影响
+func TestSampleStatsRetention(t *testing.T) {
+	s := newTestProxyService(t)
+	const name = "stats-retention"
+	addTestProxy(t, s, message.ProxyMsg{ProxyName: name})
+	defer traffic.remove(name)
+
+	now := time.Now()
+	old := message.ProxyStats{
+		ProxyName:  name,
+		BytesIn:    300,
+		BytesOut:   30,
+		TotalConns: 3,
+		Items: []message.StatsPoint{
+			{Time: now.Add(-statsRetention - time.Hour).UnixNano(), BytesIn: 100, BytesOut: 10, NewConns: 1},
+			{Time: now.Add(-statsRetention - time.Minute).UnixNano(), BytesIn: 100, BytesOut: 10, NewConns: 1},
+			{Time: now.Add(-2 * time.Hour).UnixNano(), BytesIn: 100, BytesOut: 10, NewConns: 1},
+		},
+	}
+	if err := s.store.Write(StatsCollection, name, old); err != nil {
+		t.Fatal(err)
+	}
+
+	// 未开启的代理有流量时同样采样
+	counter := traffic.get(name)
+	atomic.AddInt64(&counter.bytesIn, 50)
+	atomic.AddInt64(&counter.bytesOut, 5)
+	atomic.AddInt64(&counter.newConns, 1)
+	s.SampleStats()
+
+	var saved message.ProxyStats
+	if err := s.store.Read(StatsCollection, name, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.Items) != 2 || saved.Items[0] != old.Items[2] || saved.Items[1].BytesIn != 50 {
+		t.Fatalf("采样点为 %+v", saved.Items)
+	}
+	if saved.BytesIn != 350 || saved.BytesOut != 35 || saved.TotalConns != 4 {
+		t.Fatalf("总量为 %+v", saved)
+	}
+	if atomic.LoadInt64(&counter.bytesIn) != 0 || atomic.LoadInt64(&counter.newConns) != 0 {
+		t.Fatal("采样后计数未清零")
+	}
+
+	// 查询窗口只截取时间序列
+	stats, err := s.Stats(name, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Items) != 1 || stats.BytesIn != 350 {
+		t.Fatalf("1h 窗口的统计为 %+v", stats)
+	}
+	if stats, _ = s.Stats(name, 48*time.Hour); len(stats.Items) != 2 {
+		t.Fatalf("超出保留时长的窗口返回 %d 个采样点", len(stats.Items))
+	}
+
+	// 未开启且没有流量的代理不追加采样点
+	s.SampleStats()
+	if stats, _ = s.Stats(name, 0); len(stats.Items) != 2 {
+		t.Fatalf("没有流量时追加了采样点: %+v", stats.Items)
+	}
+}
+
+// 只有开启流量统计的 tcp 代理使用插件，其余由 frpc 直接连接本地服务
+func TestStatsPluginParams(t *testing.T) {
+	cases := []struct {
+		name  string
+		proxy message.ProxyMsg
+		want  string
+	}{
+		{"未开启", message.ProxyMsg{ProxyName: "web"}, ""},
+		{"开启", message.ProxyMsg{ProxyName: "web", Stats: true}, statsPluginName},
+		{"udp", message.ProxyMsg{ProxyName: "dns", Type: "udp", Stats: true}, ""},
+	}
+	for _, c := range cases {
+		name, params := statsPluginParams(c.proxy, "127.0.0.1:8000")
+		if name != c.want || (name != "" && params["local_addr"] != "127.0.0.1:8000") {
+			t.Errorf("%s: 插件为 %q %v，期望 %q", c.name, name, params, c.want)
+		}
+	}
+}
+
+// TestStatsPlugin 插件先向本地服务写入 PROXY 协议头再双向转发，协议头不计入流量
+func TestStatsPlugin(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	headers := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		header, _ := reader.ReadString('\n')
+		headers <- header
+		io.Copy(conn, reader)
+	}()
+
+	counter := &trafficCounter{}
+	p := &statsPlugin{proxyName: "web", localAddr: l.Addr().String(), counter: counter}
+	visitor, conn := net.Pipe()
+	done := make(chan struct{})
+	header := "PROXY TCP4 10.0.0.1 10.0.0.2 4321 80\r\n"
+	go func() {
+		p.Handle(conn, nil, []byte(header))
+		close(done)
+	}()
+
+	visitor.SetDeadline(time.Now().Add(2 * time.Second))
+	if _, err := visitor.Write([]byte("ping\n")); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := bufio.NewReader(visitor).ReadString('\n')
+	if err != nil || reply != "ping\n" {
+		t.Fatalf("回显为 %q: %v", reply, err)
+	}
+	if got := <-headers; got != header {
+		t.Fatalf("本地服务收到的协议头为 %q", got)
+	}
+	if cur := atomic.LoadInt64(&counter.curConns); cur != 1 {
+		t.Fatalf("转发时当前连接数为 %d", cur)
+	}
+
+	visitor.Close()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("访问者断开后转发未结束")
+	}
+	if counter.bytesIn != 5 || counter.bytesOut != 5 || counter.newConns != 1 || counter.curConns != 0 {
+		t.Fatalf("流量计数不符合预期: %+v", counter)
+	}
+}
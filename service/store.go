@@ -0,0 +1,98 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/sdomino/scribble"
+)
+
+// Store 配置存储，collection/resource 形式的 JSON 文档
+type Store interface {
+	Read(collection, resource string, v interface{}) error
+	ReadAll(collection string) ([][]byte, error)
+	Write(collection, resource string, v interface{}) error
+	Delete(collection, resource string) error
+	// Move 写入新内容并将记录改名，目标已存在时返回 ErrRecordExists
+	Move(collection, from, to string, v interface{}) error
+}
+
+// ErrRecordExists 改名的目标记录已存在
+var ErrRecordExists = errors.New("记录已存在")
+
+// FileStore 基于 scribble 的文件存储，目录整体替换后可重新打开
+type FileStore struct {
+	mu     sync.RWMutex
+	moveMu sync.Mutex
+	dir    string
+	driver *scribble.Driver
+}
+
+// NewFileStore 打开存储目录
+func NewFileStore(dir string) (*FileStore, error) {
+	driver, err := scribble.New(dir, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir, driver: driver}, nil
+}
+
+// Dir 存储目录
+func (s *FileStore) Dir() string {
+	return s.dir
+}
+
+// Reopen 重新打开存储目录，用于恢复备份后
+func (s *FileStore) Reopen() error {
+	driver, err := scribble.New(s.dir, nil)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.driver = driver
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *FileStore) current() *scribble.Driver {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.driver
+}
+
+func (s *FileStore) Read(collection, resource string, v interface{}) error {
+	return s.current().Read(collection, resource, v)
+}
+
+func (s *FileStore) ReadAll(collection string) ([][]byte, error) {
+	return s.current().ReadAll(collection)
+}
+
+func (s *FileStore) Write(collection, resource string, v interface{}) error {
+	return s.current().Write(collection, resource, v)
+}
+
+func (s *FileStore) Delete(collection, resource string) error {
+	return s.current().Delete(collection, resource)
+}
+
+// Move 先原地写入新内容，再重命名文件，任一时刻只存在一条记录
+func (s *FileStore) Move(collection, from, to string, v interface{}) error {
+	s.moveMu.Lock()
+	defer s.moveMu.Unlock()
+
+	dst := s.recordPath(collection, to)
+	if _, err := os.Stat(dst); err == nil {
+		return ErrRecordExists
+	}
+	if err := s.Write(collection, from, v); err != nil {
+		return err
+	}
+	return os.Rename(s.recordPath(collection, from), dst)
+}
+
+func (s *FileStore) recordPath(collection, resource string) string {
+	return filepath.Join(s.dir, collection, resource+".json")
+}
@@ -0,0 +1,288 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/douguohai/frp-client/message"
+	"github.com/fatedier/frp/pkg/consts"
+)
+
+const (
+	// proxyNameMaxLen 代理名称最大长度，按字符计
+	proxyNameMaxLen = 32
+	// remotePortMin 远程端口下限，低于该值的端口需要特权，frps 通常无法监听
+	remotePortMin = 1024
+	// healthCheckMaxSeconds 健康检查超时和间隔的上限
+	healthCheckMaxSeconds = 3600
+	// healthCheckMaxFailed 健康检查失败次数的上限
+	healthCheckMaxFailed = 100
+)
+
+// proxyNamePattern 代理名称同时作为存储文件名，只允许字母、数字、中文、下划线、短横线和点，且不能以点开头
+var proxyNamePattern = regexp.MustCompile(`^[\p{L}\p{N}_-][\p{L}\p{N}_.-]*$`)
+
+// ValidationError 代理配置校验失败，携带字段错误
+type ValidationError struct {
+	Fields []message.FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msgs = append(msgs, f.Msg)
+	}
+	return ErrProxyConf.Error() + ": " + strings.Join(msgs, "; ")
+}
+
+// Is 校验错误视为 ErrProxyConf
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrProxyConf
+}
+
+// validator 收集字段错误
+type validator struct {
+	fields []message.FieldError
+}
+
+func (v *validator) add(field, format string, args ...interface{}) {
+	v.fields = append(v.fields, message.FieldError{Field: field, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) err() error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: v.fields}
+}
+
+// ValidateProxy 校验代理配置
+// others 其他已存在的代理，用于检测远程端口重复；reserved 不允许使用的远程端口，如 frps 自身端口
+func ValidateProxy(proxy message.ProxyMsg, others []message.ProxyMsg, reserved []int) error {
+	v := &validator{}
+
+	name := strings.TrimSpace(proxy.ProxyName)
+	switch {
+	case name == "":
+		v.add("proxyName", "代理名称不能为空")
+	case len([]rune(name)) > proxyNameMaxLen:
+		v.add("proxyName", "代理名称不能超过 %d 个字符", proxyNameMaxLen)
+	case !proxyNamePattern.MatchString(name):
+		v.add("proxyName", "代理名称只能包含字母、数字、中文、下划线、短横线和点，且不能以点开头")
+	}
+
+	if !validPort(proxy.LocalPort) {
+		v.add("localPort", "本地端口范围为 1-65535")
+	}
+
+	if proxy.ProbePath != "" && !strings.HasPrefix(proxy.ProbePath, "/") {
+		v.add("probePath", "探测路径必须以 / 开头")
+	}
+	if proxy.ProbeStatus != 0 && (proxy.ProbeStatus < 100 || proxy.ProbeStatus > 599) {
+		v.add("probeStatus", "期望状态码范围为 100-599")
+	}
+
+	validateHealthCheck(v, proxy)
+	validateGroup(v, proxy, others)
+	validateProxyProtocol(v, proxy)
+	validateStats(v, proxy)
+	validateSchedule(v, proxy)
+	validateExpiry(v, proxy)
+
+	switch strings.ToLower(proxy.Type) {
+	case "", consts.TCPProxy, consts.UDPProxy:
+		validateRemotePort(v, proxy, others, reserved)
+	default:
+		v.add("type", "不支持的代理类型 %s", proxy.Type)
+	}
+
+	return v.err()
+}
+
+// validateRemotePort tcp、udp 代理的远程端口为 0 时由 frps 分配，否则同类型代理的远程端口不能重复
+func validateRemotePort(v *validator, proxy message.ProxyMsg, others []message.ProxyMsg, reserved []int) {
+	port := proxy.RemotePort
+	if port == 0 {
+		return
+	}
+	if !validPort(port) {
+		v.add("remotePort", "远程端口范围为 0-65535，0 为由服务器分配")
+		return
+	}
+	if port < remotePortMin {
+		v.add("remotePort", "远程端口不能小于 %d", remotePortMin)
+		return
+	}
+	for _, r := range reserved {
+		if port == r {
+			v.add("remotePort", "远程端口 %d 为保留端口", port)
+			return
+		}
+	}
+	for _, other := range others {
+		if proxy.Group != "" && other.Group == proxy.Group {
+			// 同组代理共用远程端口，由 validateGroup 校验
+			continue
+		}
+		if proxyTypeOf(other) == proxyTypeOf(proxy) && other.RemotePort == port {
+			v.add("remotePort", "远程端口 %d 已被代理 %s 使用", port, other.ProxyName)
+			return
+		}
+	}
+}
+
+// validateHealthCheck 校验 frp 健康检查配置
+func validateHealthCheck(v *validator, proxy message.ProxyMsg) {
+	hc := proxy.HealthCheck
+	switch strings.ToLower(hc.Type) {
+	case "":
+		return
+	case "tcp":
+	case "http":
+		if !strings.HasPrefix(hc.URL, "/") {
+			v.add("healthCheck.url", "http 健康检查需要填写以 / 开头的路径")
+		}
+	default:
+		v.add("healthCheck.type", "健康检查方式只能为 tcp 或 http")
+		return
+	}
+	if proxyTypeOf(proxy) == consts.UDPProxy {
+		v.add("healthCheck.type", "udp 代理不支持健康检查")
+	}
+	if hc.TimeoutS < 0 || hc.TimeoutS > healthCheckMaxSeconds {
+		v.add("healthCheck.timeoutS", "超时时间范围为 0-%d 秒", healthCheckMaxSeconds)
+	}
+	if hc.IntervalS < 0 || hc.IntervalS > healthCheckMaxSeconds {
+		v.add("healthCheck.intervalS", "检查间隔范围为 0-%d 秒", healthCheckMaxSeconds)
+	}
+	if hc.MaxFailed < 0 || hc.MaxFailed > healthCheckMaxFailed {
+		v.add("healthCheck.maxFailed", "失败次数范围为 0-%d", healthCheckMaxFailed)
+	}
+}
+
+// validateGroup 负载均衡组只支持 tcp，同组代理的类型、远程端口和密钥必须一致
+func validateGroup(v *validator, proxy message.ProxyMsg, others []message.ProxyMsg) {
+	if proxy.Group == "" {
+		return
+	}
+	if len([]rune(proxy.Group)) > proxyNameMaxLen || !proxyNamePattern.MatchString(proxy.Group) {
+		v.add("group", "组名只能包含字母、数字、中文、下划线、短横线和点，且不超过 %d 个字符", proxyNameMaxLen)
+		return
+	}
+	if proxyTypeOf(proxy) != consts.TCPProxy {
+		v.add("group", "负载均衡组只支持 tcp 代理")
+		return
+	}
+	if proxy.RemotePort == 0 {
+		v.add("remotePort", "负载均衡组需要指定远程端口")
+	}
+	for _, other := range others {
+		if other.Group != proxy.Group {
+			continue
+		}
+		if proxyTypeOf(other) != proxyTypeOf(proxy) {
+			v.add("type", "组内代理 %s 的类型为 %s，必须一致", other.ProxyName, proxyTypeOf(other))
+		}
+		if other.RemotePort != proxy.RemotePort {
+			v.add("remotePort", "组内代理 %s 的远程端口为 %d，必须一致", other.ProxyName, other.RemotePort)
+		}
+		if other.GroupKey != proxy.GroupKey {
+			v.add("groupKey", "组内代理 %s 的组密钥不同，必须一致", other.ProxyName)
+		}
+		return
+	}
+}
+
+// validateProxyProtocol PROXY 协议只支持 tcp 代理，版本为 v1 或 v2
+func validateProxyProtocol(v *validator, proxy message.ProxyMsg) {
+	switch strings.ToLower(proxy.ProxyProtocolVersion) {
+	case "":
+		return
+	case "v1", "v2":
+	default:
+		v.add("proxyProtocolVersion", "PROXY 协议版本只能为 v1 或 v2")
+		return
+	}
+	if proxyTypeOf(proxy) != consts.TCPProxy {
+		v.add("proxyProtocolVersion", "PROXY 协议只支持 tcp 代理")
+	}
+}
+
+// validateStats udp 代理 frpc 不经过插件，无法统计流量
+func validateStats(v *validator, proxy message.ProxyMsg) {
+	if proxy.Stats && proxyTypeOf(proxy) != consts.TCPProxy {
+		v.add("stats", "流量统计只支持 tcp 代理")
+	}
+}
+
+// validateSchedule 校验定时开关配置，cron 与时间窗口只能设置一个
+func validateSchedule(v *validator, proxy message.ProxyMsg) {
+	conf := proxy.Schedule
+	if tz := strings.TrimSpace(conf.Timezone); tz != "" {
+		if _, err := time.LoadLocation(tz); err != nil {
+			v.add("schedule.timezone", "未知的时区 %s", tz)
+		}
+	}
+	cronSpec, windows := strings.TrimSpace(conf.Cron), strings.TrimSpace(conf.Windows)
+	switch {
+	case cronSpec != "" && windows != "":
+		v.add("schedule.windows", "cron 表达式和时间窗口只能设置一个")
+	case cronSpec != "":
+		if _, err := parseCron(cronSpec); err != nil {
+			v.add("schedule.cron", "%v", err)
+		}
+	case windows != "":
+		if _, err := parseWindows(windows); err != nil {
+			v.add("schedule.windows", "%v", err)
+		}
+	}
+}
+
+// validateExpiry 校验到期设置，expireIn 为正的时长
+// 定时开关会重新开启到期关闭的代理，两者不能同时设置
+func validateExpiry(v *validator, proxy message.ProxyMsg) {
+	switch strings.ToLower(proxy.ExpireAction) {
+	case "", message.ExpireActionDisable, message.ExpireActionDelete:
+	default:
+		v.add("expireAction", "到期操作只能为 disable 或 delete")
+	}
+	if proxy.ExpireIn != "" {
+		if d, err := time.ParseDuration(proxy.ExpireIn); err != nil || d <= 0 {
+			v.add("expireIn", "有效时长 %q 应为正的时长，如 30m、2h", proxy.ExpireIn)
+		}
+	}
+	if proxy.ExpireAt < 0 {
+		v.add("expireAt", "到期时间不能为负数")
+	}
+	if strings.TrimSpace(proxy.Schedule.Cron) == "" && strings.TrimSpace(proxy.Schedule.Windows) == "" {
+		return
+	}
+	switch {
+	case proxy.ExpireIn != "":
+		v.add("expireIn", "设置了定时开关的代理不能设置到期")
+	case proxy.ExpireAt > 0:
+		v.add("expireAt", "设置了定时开关的代理不能设置到期")
+	}
+}
+
+// checkExpireAt 新设置的到期时间不能早于当前时间，previous 为原到期时间，未修改时由调度器按到期处理
+func checkExpireAt(proxy message.ProxyMsg, previous int64) error {
+	if proxy.ExpireIn != "" || proxy.ExpireAt == 0 || proxy.ExpireAt == previous || proxy.ExpireAt > time.Now().UnixNano() {
+		return nil
+	}
+	return &ValidationError{Fields: []message.FieldError{{Field: "expireAt", Msg: "到期时间已过"}}}
+}
+
+// proxyTypeOf 代理类型，旧数据为空时视为 tcp
+func proxyTypeOf(proxy message.ProxyMsg) string {
+	if proxy.Type == "" {
+		return consts.TCPProxy
+	}
+	return strings.ToLower(proxy.Type)
+}
+
+func validPort(port int) bool {
+	return port > 0 && port <= 65535
+}
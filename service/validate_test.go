@@ -0,0 +1,98 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/douguohai/frp-client/message"
+)
+
+func TestValidateProxy(t *testing.T) {
+	web := message.ProxyMsg{ProxyName: "web", LocalPort: 80, RemotePort: 8080}
+	lb := message.ProxyMsg{ProxyName: "lb-1", LocalPort: 80, RemotePort: 9000, Group: "lb", GroupKey: "key"}
+	valid := func(p message.ProxyMsg) message.ProxyMsg {
+		if p.ProxyName == "" {
+			p.ProxyName = "test"
+		}
+		if p.LocalPort == 0 {
+			p.LocalPort = 8000
+		}
+		return p
+	}
+
+	cases := []struct {
+		name     string
+		proxy    message.ProxyMsg
+		others   []message.ProxyMsg
+		reserved []int
+		fields   []string
+	}{
+		{"最简配置", valid(message.ProxyMsg{}), nil, nil, nil},
+		{"中文名称", valid(message.ProxyMsg{ProxyName: "网站_1.v2"}), nil, nil, nil},
+		{"名称为空", message.ProxyMsg{ProxyName: " ", LocalPort: 80}, nil, nil, []string{"proxyName"}},
+		{"名称过长", valid(message.ProxyMsg{ProxyName: "abcdefghijklmnopqrstuvwxyz0123456"}), nil, nil, []string{"proxyName"}},
+		{"名称含路径", valid(message.ProxyMsg{ProxyName: "../web"}), nil, nil, []string{"proxyName"}},
+		{"名称以点开头", valid(message.ProxyMsg{ProxyName: ".web"}), nil, nil, []string{"proxyName"}},
+		{"本地端口为 0", message.ProxyMsg{ProxyName: "test"}, nil, nil, []string{"localPort"}},
+		{"本地端口越界", message.ProxyMsg{ProxyName: "test", LocalPort: 65536}, nil, nil, []string{"localPort"}},
+		{"探测路径", valid(message.ProxyMsg{ProbePath: "health", ProbeStatus: 99}), nil, nil, []string{"probePath", "probeStatus"}},
+		{"不支持的类型", valid(message.ProxyMsg{Type: "http"}), nil, nil, []string{"type"}},
+
+		{"服务器分配端口", valid(message.ProxyMsg{RemotePort: 0}), []message.ProxyMsg{{ProxyName: "auto"}}, nil, nil},
+		{"远程端口越界", valid(message.ProxyMsg{RemotePort: 70000}), nil, nil, []string{"remotePort"}},
+		{"远程端口需要特权", valid(message.ProxyMsg{RemotePort: 80}), nil, nil, []string{"remotePort"}},
+		{"远程端口保留", valid(message.ProxyMsg{RemotePort: 7000}), nil, []int{7000}, []string{"remotePort"}},
+		{"远程端口重复", valid(message.ProxyMsg{RemotePort: 8080}), []message.ProxyMsg{web}, nil, []string{"remotePort"}},
+		{"不同类型可用相同端口", valid(message.ProxyMsg{Type: "UDP", RemotePort: 8080}), []message.ProxyMsg{web}, nil, nil},
+
+		{"tcp 健康检查", valid(message.ProxyMsg{HealthCheck: message.HealthCheckConf{Type: "tcp", TimeoutS: 3, IntervalS: 10, MaxFailed: 3}}), nil, nil, nil},
+		{"http 健康检查缺少路径", valid(message.ProxyMsg{HealthCheck: message.HealthCheckConf{Type: "http"}}), nil, nil, []string{"healthCheck.url"}},
+		{"未知的健康检查方式", valid(message.ProxyMsg{HealthCheck: message.HealthCheckConf{Type: "icmp"}}), nil, nil, []string{"healthCheck.type"}},
+		{"udp 健康检查", valid(message.ProxyMsg{Type: "udp", HealthCheck: message.HealthCheckConf{Type: "tcp"}}), nil, nil, []string{"healthCheck.type"}},
+		{"健康检查参数越界", valid(message.ProxyMsg{HealthCheck: message.HealthCheckConf{Type: "tcp", TimeoutS: -1, IntervalS: 3601, MaxFailed: 101}}), nil, nil,
+			[]string{"healthCheck.timeoutS", "healthCheck.intervalS", "healthCheck.maxFailed"}},
+
+		{"加入负载均衡组", valid(message.ProxyMsg{RemotePort: 9000, Group: "lb", GroupKey: "key"}), []message.ProxyMsg{lb}, nil, nil},
+		{"组内配置不一致", valid(message.ProxyMsg{RemotePort: 9001, Group: "lb", GroupKey: "other"}), []message.ProxyMsg{lb}, nil, []string{"remotePort", "groupKey"}},
+		{"组需要远程端口", valid(message.ProxyMsg{Group: "lb"}), nil, nil, []string{"remotePort"}},
+		{"udp 组", valid(message.ProxyMsg{Type: "udp", RemotePort: 9000, Group: "lb"}), nil, nil, []string{"group"}},
+		{"组名错误", valid(message.ProxyMsg{RemotePort: 9000, Group: "a/b"}), nil, nil, []string{"group"}},
+
+		{"PROXY 协议", valid(message.ProxyMsg{ProxyProtocolVersion: "V2"}), nil, nil, nil},
+		{"PROXY 协议版本错误", valid(message.ProxyMsg{ProxyProtocolVersion: "v3"}), nil, nil, []string{"proxyProtocolVersion"}},
+		{"udp PROXY 协议", valid(message.ProxyMsg{Type: "udp", ProxyProtocolVersion: "v1"}), nil, nil, []string{"proxyProtocolVersion"}},
+
+		{"流量统计", valid(message.ProxyMsg{Stats: true}), nil, nil, nil},
+		{"udp 流量统计", valid(message.ProxyMsg{Type: "udp", Stats: true}), nil, nil, []string{"stats"}},
+
+		{"定时开关", valid(message.ProxyMsg{Schedule: message.ScheduleConf{Windows: "mon-fri 09:00-18:00", Timezone: "Asia/Shanghai"}}), nil, nil, nil},
+		{"cron 与时间窗口同时设置", valid(message.ProxyMsg{Schedule: message.ScheduleConf{Cron: "* * * * *", Windows: "09:00-18:00"}}), nil, nil, []string{"schedule.windows"}},
+		{"cron 错误", valid(message.ProxyMsg{Schedule: message.ScheduleConf{Cron: "* 24 * * *", Timezone: "Mars/Olympus"}}), nil, nil, []string{"schedule.timezone", "schedule.cron"}},
+		{"时间窗口错误", valid(message.ProxyMsg{Schedule: message.ScheduleConf{Windows: "someday 09:00-18:00"}}), nil, nil, []string{"schedule.windows"}},
+
+		{"到期设置", valid(message.ProxyMsg{ExpireIn: "90m", ExpireAction: "Delete"}), nil, nil, nil},
+		{"定时开关与有效时长", valid(message.ProxyMsg{Schedule: message.ScheduleConf{Cron: "* 9 * * *"}, ExpireIn: "1h"}), nil, nil, []string{"expireIn"}},
+		{"定时开关与到期时间", valid(message.ProxyMsg{Schedule: message.ScheduleConf{Windows: "09:00-18:00"}, ExpireAt: 1}), nil, nil, []string{"expireAt"}},
+		{"只设置时区不算定时开关", valid(message.ProxyMsg{Schedule: message.ScheduleConf{Timezone: "UTC"}, ExpireIn: "1h"}), nil, nil, nil},
+		{"到期设置错误", valid(message.ProxyMsg{ExpireIn: "-1h", ExpireAction: "archive", ExpireAt: -1}), nil, nil, []string{"expireAction", "expireIn", "expireAt"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := ValidateProxy(c.proxy, c.others, c.reserved)
+			var fields []string
+			if err != nil {
+				var verr *ValidationError
+				if !errors.As(err, &verr) || !errors.Is(err, ErrProxyConf) {
+					t.Fatalf("错误类型为 %T: %v", err, err)
+				}
+				for _, f := range verr.Fields {
+					fields = append(fields, f.Field)
+				}
+			}
+			if !reflect.DeepEqual(fields, c.fields) {
+				t.Fatalf("字段错误为 %v，期望 %v (%v)", fields, c.fields, err)
+			}
+		})
+	}
+}